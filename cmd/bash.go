@@ -2,20 +2,31 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
 
 	"github.com/atotto/clipboard"
 	"github.com/chzyer/readline"
 	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/prompts"
+	"github.com/misrab/clai/internal/safety"
 	"github.com/spf13/cobra"
 )
 
 var (
-	bashReplMode bool
+	bashReplMode    bool
+	bashTemplate    string
+	bashCandidates  int
+	bashShowContext bool
+
+	// bashDefaultOptions keep command generation deterministic
+	bashDefaultOptions = ai.Options{Temperature: ai.Float(0)}
 
 	bashCmd = &cobra.Command{
 		Use:   "bash [prompt]",
@@ -23,8 +34,23 @@ var (
 		Long:  "Converts natural language prompts into bash commands and executes them with your approval.",
 		Args:  cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if bashShowContext {
+				printEnvironment(prompts.CurrentVars())
+				return nil
+			}
+
+			if bashCandidates < 1 || bashCandidates > maxCandidates {
+				return fmt.Errorf("--candidates must be between 1 and %d", maxCandidates)
+			}
+
+			if !useDummy {
+				if err := ensureModelInstalled(cmd.Context(), bashModelName(), fallbackModels...); err != nil {
+					return err
+				}
+			}
+
 			if bashReplMode {
-				return runBashREPL()
+				return runBashREPL(cmd.Context())
 			}
 
 			if len(args) == 0 {
@@ -32,35 +58,54 @@ var (
 			}
 
 			prompt := strings.Join(args, " ")
-			return handleBashPrompt(prompt)
+			return handleBashPrompt(cmd.Context(), prompt)
 		},
 	}
 )
 
 func init() {
 	bashCmd.Flags().BoolVar(&bashReplMode, "repl", false, "Start in REPL (interactive) mode")
+	bashCmd.Flags().IntVarP(&bashCandidates, "candidates", "n", 1, "Generate several distinct commands and pick one")
+	bashCmd.Flags().StringVar(&bashTemplate, "prompt-template", "bash", "System prompt template (see: clai prompts)")
+	bashCmd.Flags().BoolVar(&bashShowContext, "show-context", false, "Show the environment described to the model and exit")
 	rootCmd.AddCommand(bashCmd)
 }
 
 // handleBashPrompt processes a single bash prompt
-func handleBashPrompt(prompt string) error {
+func handleBashPrompt(ctx context.Context, prompt string) error {
 	if err := validatePromptLength(prompt); err != nil {
 		return err
 	}
 
-	command, err := generateCommand(prompt)
+	if bashCandidates > 1 {
+		command, err := pickCommand(ctx, prompt, bashCandidates)
+		if err != nil {
+			return fmt.Errorf("failed to generate commands: %w", err)
+		}
+		if command == nil {
+			return nil
+		}
+		fmt.Printf("\nSelected command:\n  %s\n\n", formatCommand(command.Command))
+		return promptAndExecute(command)
+	}
+
+	view := newCommandView("\nGenerated command:\n  ")
+	command, cached, err := generateCommand(ctx, prompt, view.write)
+	view.finish()
 	if err != nil {
 		return fmt.Errorf("failed to generate command: %w", err)
 	}
 
-	fmt.Printf("\nGenerated command:\n")
-	fmt.Printf("  %s\n\n", formatCommand(command))
+	fmt.Printf("\nGenerated command%s:\n", cachedMarker(cached))
+	fmt.Printf("  %s\n\n", formatCommand(command.Command))
+	printAnsweredBy(command.Metrics, bashModelName())
+	printMetrics(command.Metrics)
 
 	return promptAndExecute(command)
 }
 
 // runBashREPL starts the interactive bash REPL mode
-func runBashREPL() error {
+func runBashREPL(ctx context.Context) error {
 	scanner := bufio.NewScanner(os.Stdin)
 
 	fmt.Println("clai bash REPL - Type your requests (Ctrl+C or 'exit' to quit)")
@@ -87,13 +132,42 @@ func runBashREPL() error {
 			continue
 		}
 
-		command, err := generateCommand(prompt)
+		if bashCandidates > 1 {
+			// Ctrl+C while generating or choosing cancels only this request
+			reqCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+			command, err := pickCommand(reqCtx, prompt, bashCandidates)
+			interrupted := errors.Is(err, context.Canceled) || reqCtx.Err() != nil
+			stop()
+			if interrupted {
+				fmt.Println("\n\033[2m(interrupted)\033[0m")
+			} else if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			} else if command != nil {
+				if err := promptAndExecute(command); err != nil {
+					fmt.Printf("Error: %v\n", err)
+				}
+			}
+			continue
+		}
+
+		// Ctrl+C while generating cancels only this request
+		reqCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		view := newCommandView("Generated: ")
+		command, cached, err := generateCommand(reqCtx, prompt, view.write)
+		view.finish()
+		stop()
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("\n\033[2m(interrupted)\033[0m")
+			continue
+		}
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
 			continue
 		}
 
-		fmt.Printf("Generated: %s\n", formatCommand(command))
+		fmt.Printf("Generated%s: %s\n", cachedMarker(cached), formatCommand(command.Command))
+		printAnsweredBy(command.Metrics, bashModelName())
+		printMetrics(command.Metrics)
 
 		if err := promptAndExecute(command); err != nil {
 			fmt.Printf("Error: %v\n", err)
@@ -103,12 +177,29 @@ func runBashREPL() error {
 	return nil
 }
 
-// promptAndExecute asks for confirmation and executes the command
-func promptAndExecute(command string) error {
+// promptAndExecute shows what the command does, asks for confirmation and
+// executes it. Dangerous commands need "yes" typed out.
+func promptAndExecute(generated *ai.Command) error {
 	reader := bufio.NewReader(os.Stdin)
+	command := generated.Command
 
+	if len(checkCommand(command)) > 0 {
+		generated.Risk = ai.RiskHigh
+	}
+	printCommandDetails(generated)
+
+	warned := ""
 	for {
-		fmt.Print("Execute? [Y/n/e/c] ")
+		dangers := checkCommand(command)
+		if len(dangers) > 0 {
+			if warned != command {
+				printDangers(dangers)
+				warned = command
+			}
+			fmt.Print("Type yes to execute, or [n/e/c] ")
+		} else {
+			fmt.Print("Execute? [Y/n/e/c] ")
+		}
 		response, err := reader.ReadString('\n')
 		if err != nil {
 			return err
@@ -118,35 +209,24 @@ func promptAndExecute(command string) error {
 
 		switch response {
 		case "", "y", "yes":
+			if len(dangers) > 0 && response != "yes" {
+				fmt.Println("This command is dangerous: type yes in full to execute it")
+				continue
+			}
 			return executeCommand(command)
 		case "n", "no":
 			fmt.Println("Cancelled")
 			return nil
 		case "e", "edit":
-			rl, err := readline.NewEx(&readline.Config{
-				Prompt:                 "Edit: ",
-				InterruptPrompt:        "^C",
-				HistoryLimit:           0,
-				DisableAutoSaveHistory: true,
-			})
+			edited, ok, err := editLine(command)
 			if err != nil {
 				return err
 			}
-			// Prefill with current command
-			rl.WriteStdin([]byte(command))
-			edited, err := rl.Readline()
-			rl.Close()
-			if err != nil {
-				if err == io.EOF || err == readline.ErrInterrupt {
-					fmt.Println("Cancelled")
-					return nil
-				}
-				return err
-			}
-			edited = strings.TrimSpace(edited)
-			if edited != "" {
-				command = edited
+			if !ok {
+				fmt.Println("Cancelled")
+				return nil
 			}
+			command = edited
 			continue
 		case "c", "copy":
 			if err := clipboard.WriteAll(command); err != nil {
@@ -161,6 +241,37 @@ func promptAndExecute(command string) error {
 	}
 }
 
+// editLine lets the user edit text prefilled at an "Edit: " prompt. ok is
+// false when the edit was aborted with Ctrl+C or Ctrl+D; an empty edit keeps text.
+func editLine(text string) (edited string, ok bool, err error) {
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:                 "Edit: ",
+		InterruptPrompt:        "^C",
+		HistoryLimit:           0,
+		DisableAutoSaveHistory: true,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	defer rl.Close()
+
+	// Prefill with the current text
+	rl.WriteStdin([]byte(text))
+	edited, err = rl.Readline()
+	if err != nil {
+		if err == io.EOF || err == readline.ErrInterrupt {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	edited = strings.TrimSpace(edited)
+	if edited == "" {
+		return text, true, nil
+	}
+	return edited, true, nil
+}
+
 // executeCommand runs the shell command
 func executeCommand(command string) error {
 	fmt.Println("Executing...")
@@ -178,18 +289,53 @@ func executeCommand(command string) error {
 	return nil
 }
 
-// generateCommand generates a shell command using AI or dummy mode
-func generateCommand(prompt string) (string, error) {
+// generateCommand generates a shell command using AI or dummy mode. cached
+// reports whether the command was reused from an earlier identical request.
+// If stream is set and the provider supports it, the command text is passed
+// to stream as it arrives.
+func generateCommand(ctx context.Context, prompt string, stream func(string) error) (command *ai.Command, cached bool, err error) {
 	if useDummy {
-		return generateDummyCommand(prompt), nil
+		return &ai.Command{
+			Command:     generateDummyCommand(prompt),
+			Explanation: "Pattern-based dummy command",
+			Risk:        ai.RiskMedium,
+		}, false, nil
 	}
 
-	client := ai.NewClient(aiModel)
-	cmd, err := client.GenerateCommand(prompt)
+	template, err := loadSystemPrompt(bashTemplate)
+	if err != nil {
+		return nil, false, err
+	}
+	vars := prompts.CurrentVars()
+	system, err := prompts.Render(template, vars)
 	if err != nil {
-		return "", err
+		return nil, false, err
 	}
-	return cmd, nil
+
+	cache := openCommandCache()
+	defer cache.close()
+	chain := modelChain(bashModelName())
+	opts := generationOptions(bashDefaultOptions)
+	key := commandCacheKey(strings.Join(chain, ","), opts, template, prompt, vars)
+	if command := cache.get(key); command != nil {
+		return command, true, nil
+	}
+
+	provider, err := newRoutedProvider(chain[0], opts, system)
+	if err != nil {
+		return nil, false, err
+	}
+	if streamer, ok := provider.(ai.CommandStreamer); ok && stream != nil {
+		command, err = streamer.GenerateCommandStream(ctx, prompt, stream)
+	} else {
+		command, err = provider.GenerateCommand(ctx, prompt)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	cache.put(key, command.Metrics.Model, prompt, command)
+	return command, false, nil
 }
 
 // generateDummyCommand is a simple pattern-based command generator
@@ -215,7 +361,56 @@ func generateDummyCommand(prompt string) string {
 	}
 }
 
-// formatCommand returns the command with cyan coloring
+// formatCommand returns the command in cyan, or in bold red when it is dangerous
 func formatCommand(cmd string) string {
+	if len(checkCommand(cmd)) > 0 {
+		return fmt.Sprintf("\033[1;31m%s\033[0m", cmd)
+	}
 	return fmt.Sprintf("\033[36m%s\033[0m", cmd)
 }
+
+// checkCommand returns the dangerous parts of a shell command run in the current directory
+func checkCommand(command string) []safety.Danger {
+	cwd, _ := os.Getwd()
+	home, _ := os.UserHomeDir()
+	return safety.Check(command, cwd, home)
+}
+
+// printDangers warns in red about the dangerous parts of a command
+func printDangers(dangers []safety.Danger) {
+	for _, d := range dangers {
+		fmt.Printf("\033[1;31m⚠ %s:\033[0m \033[31m%s\033[0m\n", d.Command, d.Reason)
+	}
+}
+
+// cachedMarker returns a dimmed " (cached)" for commands reused from the cache
+func cachedMarker(cached bool) string {
+	if !cached {
+		return ""
+	}
+	return " \033[2m(cached)\033[0m"
+}
+
+// printCommandDetails prints the model's explanation, the files the command
+// touches and its risk level
+func printCommandDetails(cmd *ai.Command) {
+	if cmd.Explanation != "" {
+		fmt.Printf("  %s\n", cmd.Explanation)
+	}
+	if len(cmd.Files) > 0 {
+		fmt.Printf("  Files: %s\n", strings.Join(cmd.Files, ", "))
+	}
+	fmt.Printf("  Risk: %s\n\n", formatRisk(cmd.Risk))
+}
+
+// formatRisk returns the risk level colored green, yellow or red
+func formatRisk(risk string) string {
+	switch risk {
+	case ai.RiskLow:
+		return fmt.Sprintf("\033[32m%s\033[0m", risk)
+	case ai.RiskHigh:
+		return fmt.Sprintf("\033[1;31m%s\033[0m", risk)
+	default:
+		return fmt.Sprintf("\033[33m%s\033[0m", risk)
+	}
+}
@@ -1,6 +1,20 @@
 package cmd
 
-import "testing"
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/ai/aitest"
+)
 
 func TestGenerateDummyCommand(t *testing.T) {
 	t.Parallel()
@@ -45,3 +59,274 @@ func TestGenerateDummyCommand(t *testing.T) {
 	}
 }
 
+// useFakeOllama points the commands at a fake Ollama server, with the cache
+// and user configuration out of the way. Tests using it can't run in parallel.
+func useFakeOllama(t *testing.T) *aitest.Server {
+	t.Helper()
+
+	srv := aitest.NewServer(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	prevURL, prevModel, prevRetries, prevNoCache, prevDummy := ollamaURL, aiModel, aiRetries, noCache, useDummy
+	ollamaURL, aiModel, aiRetries, noCache, useDummy = srv.URL, aitest.DefaultModel, 0, true, false
+	t.Cleanup(func() {
+		ollamaURL, aiModel, aiRetries, noCache, useDummy = prevURL, prevModel, prevRetries, prevNoCache, prevDummy
+	})
+	return srv
+}
+
+func TestGenerateCommandWithOllama(t *testing.T) {
+	srv := useFakeOllama(t)
+	srv.Enqueue(aitest.Command(ai.Command{
+		Command:     "du -ah . | sort -rh | head -n 10",
+		Explanation: "Lists the largest files",
+		Risk:        ai.RiskLow,
+	}))
+
+	var streamed strings.Builder
+	command, cached, err := generateCommand(context.Background(), "find large files", func(chunk string) error {
+		streamed.WriteString(chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("generateCommand: %v", err)
+	}
+	if cached {
+		t.Error("command reported as cached with --no-cache")
+	}
+	if command.Command != "du -ah . | sort -rh | head -n 10" || command.Risk != ai.RiskLow {
+		t.Errorf("command = %+v, want the scripted one", command)
+	}
+	if streamed.String() != command.Command {
+		t.Errorf("streamed %q, want %q", streamed.String(), command.Command)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if req := requests[0]; !req.Stream || !strings.Contains(req.Prompt, "find large files") || !strings.Contains(req.System, "bash command generator") {
+		t.Errorf("request = %+v, want a streamed request with the prompt and the bash system prompt", req)
+	}
+}
+
+func TestGenerateCommandWithOllamaErrors(t *testing.T) {
+	srv := useFakeOllama(t)
+
+	srv.Enqueue(aitest.Response{Status: http.StatusNotFound, Error: "model 'aitest:latest' not found"})
+	if _, _, err := generateCommand(context.Background(), "list files", nil); !errors.Is(err, ai.ErrModelNotFound) {
+		t.Errorf("generateCommand error = %v, want ErrModelNotFound", err)
+	}
+
+	srv.Enqueue(aitest.Response{Delay: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := generateCommand(ctx, "list files", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("generateCommand error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestGenerateCommandCache(t *testing.T) {
+	srv := useFakeOllama(t)
+	prevNoCache, prevTTL, prevOptions := noCache, cacheTTL, bashDefaultOptions
+	noCache, cacheTTL = false, time.Hour
+	t.Cleanup(func() { noCache, cacheTTL, bashDefaultOptions = prevNoCache, prevTTL, prevOptions })
+
+	generate := func() bool {
+		t.Helper()
+		_, cached, err := generateCommand(context.Background(), "list files", nil)
+		if err != nil {
+			t.Fatalf("generateCommand: %v", err)
+		}
+		return cached
+	}
+
+	if generate() || !generate() {
+		t.Fatal("the same request twice was not answered from the cache")
+	}
+
+	// Other options or another backend may answer differently
+	bashDefaultOptions = ai.Options{Temperature: ai.Float(0.5)}
+	if generate() {
+		t.Error("command cached for other options")
+	}
+	other := aitest.NewServer(t)
+	ollamaURL = other.URL
+	if generate() {
+		t.Error("command cached for another backend")
+	}
+
+	if got := len(srv.Requests()) + len(other.Requests()); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+}
+
+func TestRecordAndReplaySkipCache(t *testing.T) {
+	srv := useFakeOllama(t)
+	path := filepath.Join(t.TempDir(), "session.json")
+	prevNoCache, prevTTL := noCache, cacheTTL
+	noCache, cacheTTL, recordCassette = false, time.Hour, path
+	t.Cleanup(func() {
+		noCache, cacheTTL, recordCassette, replayCassette, cassette = prevNoCache, prevTTL, "", "", nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, cached, err := generateCommand(context.Background(), "list files", nil); err != nil || cached {
+			t.Fatalf("generateCommand while recording: cached = %v, err = %v, want a fresh answer", cached, err)
+		}
+	}
+	if got := len(srv.Requests()); got != 2 {
+		t.Fatalf("got %d requests while recording, want 2", got)
+	}
+
+	recordCassette, replayCassette, cassette = "", path, nil
+	for i := 0; i < 2; i++ {
+		command, cached, err := generateCommand(context.Background(), "list files", nil)
+		if err != nil || cached {
+			t.Fatalf("generateCommand while replaying: cached = %v, err = %v, want an answer from the cassette", cached, err)
+		}
+		if command.Command != srv.DefaultCommand.Command {
+			t.Errorf("replayed command = %q, want %q", command.Command, srv.DefaultCommand.Command)
+		}
+	}
+	if got := len(srv.Requests()); got != 2 {
+		t.Errorf("got %d requests in total, want none while replaying", got)
+	}
+}
+
+func TestGenerateCandidates(t *testing.T) {
+	srv := useFakeOllama(t)
+	srv.Enqueue(
+		aitest.Command(ai.Command{Command: "du -sh *", Risk: ai.RiskLow}),
+		aitest.Command(ai.Command{Command: "du  -sh *", Risk: ai.RiskLow}),
+		aitest.Command(ai.Command{Command: "find . -size +100M", Risk: ai.RiskLow}),
+	)
+
+	candidates, err := generateCandidates(context.Background(), "find large files", 2)
+	if err != nil {
+		t.Fatalf("generateCandidates: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].Command != "du -sh *" || candidates[1].Command != "find . -size +100M" {
+		t.Fatalf("candidates = %+v, want two distinct commands", candidates)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(requests))
+	}
+	if !strings.Contains(requests[1].Prompt, "- du -sh *") {
+		t.Errorf("second prompt = %q, want it to list the first candidate", requests[1].Prompt)
+	}
+}
+
+func TestChooseCandidate(t *testing.T) {
+	candidates := []*ai.Command{{Command: "ls"}, {Command: "ls -la"}}
+
+	tests := []struct {
+		input      string
+		command    string
+		regenerate bool
+	}{
+		{input: "\n", command: "ls"},
+		{input: "2\n", command: "ls -la"},
+		{input: "7\n1\n", command: "ls"},
+		{input: "r\n", regenerate: true},
+		{input: "q\n"},
+	}
+
+	for _, tt := range tests {
+		command, regenerate, err := chooseCandidate(bufio.NewReader(strings.NewReader(tt.input)), candidates)
+		if err != nil {
+			t.Fatalf("chooseCandidate(%q): %v", tt.input, err)
+		}
+		got := ""
+		if command != nil {
+			got = command.Command
+		}
+		if got != tt.command || regenerate != tt.regenerate {
+			t.Errorf("chooseCandidate(%q) = %q, %v, want %q, %v", tt.input, got, regenerate, tt.command, tt.regenerate)
+		}
+	}
+}
+
+func TestGenerateCommandFallsBack(t *testing.T) {
+	srv := useFakeOllama(t)
+	prevModel, prevFallbacks := aiModel, fallbackModels
+	aiModel, fallbackModels = "missing:7b", []string{aitest.DefaultModel}
+	t.Cleanup(func() { aiModel, fallbackModels = prevModel, prevFallbacks })
+
+	srv.Enqueue(aitest.Response{Status: http.StatusNotFound, Error: "model 'missing:7b' not found"})
+
+	command, _, err := generateCommand(context.Background(), "list files", nil)
+	if err != nil {
+		t.Fatalf("generateCommand: %v", err)
+	}
+	if command.Metrics.Model != aitest.DefaultModel {
+		t.Errorf("answered by %q, want the fallback %q", command.Metrics.Model, aitest.DefaultModel)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 2 || requests[0].Model != "missing:7b" || requests[1].Model != aitest.DefaultModel {
+		t.Errorf("requests = %+v, want the missing model and then the fallback", requests)
+	}
+}
+
+func TestPromptAndExecuteNeedsYesForDangerousCommands(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "victim")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A path in a variable can't be checked, so the delete is flagged
+	t.Setenv("CLAI_TEST_DIR", dir)
+	command := &ai.Command{Command: `rm -rf "$CLAI_TEST_DIR"`, Risk: ai.RiskLow}
+
+	withStdin(t, "\ny\nn\n")
+	if err := promptAndExecute(command); err != nil {
+		t.Fatalf("promptAndExecute: %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("directory is gone after Enter and y: %v", err)
+	}
+	if command.Risk != ai.RiskHigh {
+		t.Errorf("risk = %s, want high for a dangerous command", command.Risk)
+	}
+
+	withStdin(t, "yes\n")
+	if err := promptAndExecute(command); err != nil {
+		t.Fatalf("promptAndExecute: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("directory still exists after yes (stat: %v)", err)
+	}
+}
+
+func TestBashREPLInterruptsCandidates(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts can't be sent to the own process on Windows")
+	}
+	srv := useFakeOllama(t)
+	prevCandidates := bashCandidates
+	bashCandidates = 3
+	t.Cleanup(func() { bashCandidates = prevCandidates })
+
+	// The first request hangs until Ctrl+C cancels it
+	srv.Enqueue(aitest.Response{Delay: 10 * time.Second})
+	go func() {
+		for len(srv.Requests()) == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(os.Interrupt)
+	}()
+
+	withStdin(t, "list files\nexit\n")
+	var err error
+	out := captureStdout(t, func() { err = runBashREPL(context.Background()) })
+	if err != nil {
+		t.Fatalf("runBashREPL: %v", err)
+	}
+	if !strings.Contains(out, "(interrupted)") || !strings.Contains(out, "Goodbye!") {
+		t.Errorf("REPL printed %q, want the request interrupted and the REPL still running", out)
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/prompts"
+	"github.com/misrab/clai/internal/storage"
+)
+
+var (
+	noCache  bool
+	cacheTTL time.Duration
+)
+
+func init() {
+	bashCmd.Flags().BoolVar(&noCache, "no-cache", false, "Always ask the model instead of reusing a cached command")
+	bashCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 24*time.Hour, "How long generated commands are reused (0 disables the cache)")
+}
+
+// commandCache reuses commands generated for the same request. It is best
+// effort: a nil cache, or any database error, just means asking the model.
+type commandCache struct {
+	store *storage.Store
+}
+
+// openCommandCache opens the cache, or returns nil when it is disabled or
+// the database is unavailable. Recording and replaying disable it, since a
+// cached answer would bypass the cassette.
+func openCommandCache() *commandCache {
+	if noCache || cacheTTL <= 0 || recordCassette != "" || replayCassette != "" {
+		return nil
+	}
+	store, err := storage.OpenStore(io.Discard)
+	if err != nil {
+		return nil
+	}
+	return &commandCache{store: store}
+}
+
+// commandCacheKey identifies a request to model on the configured backend,
+// with the given options and system prompt template, in the current environment
+func commandCacheKey(model string, opts ai.Options, template, prompt string, vars prompts.Vars) string {
+	templateHash := sha256.Sum256([]byte(template))
+	options, _ := json.Marshal(opts)
+	return storage.CommandCacheKey(aiProvider, providerBaseURL(), model, string(options),
+		hex.EncodeToString(templateHash[:]), prompt, envFingerprint(vars))
+}
+
+// envFingerprint describes the environment a command was generated for.
+// The date and uncommitted changes are left out so answers stay cached
+// across days and edits.
+func envFingerprint(vars prompts.Vars) string {
+	return strings.Join([]string{
+		vars.OS, vars.Arch, vars.Shell, vars.User, vars.Cwd,
+		vars.Coreutils, vars.GitBranch, strings.Join(vars.Tools, ","),
+	}, "\n")
+}
+
+// get returns the cached command for key, or nil
+func (c *commandCache) get(key string) *ai.Command {
+	if c == nil {
+		return nil
+	}
+	cached, err := c.store.GetCachedCommand(key, cacheTTL)
+	if err != nil || cached == nil {
+		return nil
+	}
+
+	var cmd ai.Command
+	if err := json.Unmarshal([]byte(cached.Response), &cmd); err != nil || cmd.Command == "" {
+		return nil
+	}
+	return &cmd
+}
+
+// put stores a generated command under key
+func (c *commandCache) put(key, model, prompt string, cmd *ai.Command) {
+	if c == nil {
+		return
+	}
+	response, err := json.Marshal(cmd)
+	if err != nil {
+		return
+	}
+	c.store.PutCachedCommand(&storage.CachedCommand{
+		Key:       key,
+		Model:     model,
+		Prompt:    prompt,
+		Response:  string(response),
+		CreatedAt: time.Now(),
+	}, cacheTTL)
+}
+
+// close closes the database
+func (c *commandCache) close() {
+	if c != nil {
+		c.store.Close()
+	}
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/misrab/clai/internal/ai"
+)
+
+// maxCandidates keeps the picker to single-digit choices
+const maxCandidates = 9
+
+// candidateDefaultOptions add some variety so candidates differ. Each
+// candidate also gets its own seed.
+var candidateDefaultOptions = ai.Options{Temperature: ai.Float(0.8)}
+
+// pickCommand generates n candidate commands and lets the user pick, edit or
+// regenerate them. It returns nil if the user quits.
+func pickCommand(ctx context.Context, prompt string, n int) (*ai.Command, error) {
+	if n > maxCandidates {
+		return nil, fmt.Errorf("at most %d candidates are supported", maxCandidates)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		view := newCommandView("")
+		candidates, err := generateCandidates(ctx, prompt, n)
+		view.finish()
+		if err != nil {
+			return nil, err
+		}
+
+		command, regenerate, err := chooseCandidate(reader, candidates)
+		if err != nil || !regenerate {
+			return command, err
+		}
+	}
+}
+
+// generateCandidates asks the model up to 2n times for distinct commands.
+// Later requests list the commands found so far and ask for an alternative.
+func generateCandidates(ctx context.Context, prompt string, n int) ([]*ai.Command, error) {
+	if useDummy {
+		command, _, err := generateCommand(ctx, prompt, nil)
+		return []*ai.Command{command}, err
+	}
+
+	system, err := renderSystemPrompt(bashTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := generationOptions(candidateDefaultOptions)
+	seed := int(time.Now().UnixNano() % 1e6)
+	if opts.Seed != nil {
+		seed = *opts.Seed
+	}
+
+	var candidates []*ai.Command
+	seen := map[string]bool{}
+	for attempt := 0; attempt < 2*n && len(candidates) < n; attempt++ {
+		opts.Seed = ai.Int(seed + attempt)
+		provider, err := newRoutedProvider(bashModelName(), opts, system)
+		if err != nil {
+			return nil, err
+		}
+
+		command, err := provider.GenerateCommand(ctx, alternativePrompt(prompt, candidates))
+		if err != nil {
+			// Keep what we have unless nothing worked or the user gave up
+			if len(candidates) == 0 || ctx.Err() != nil {
+				return nil, err
+			}
+			break
+		}
+
+		key := strings.Join(strings.Fields(command.Command), " ")
+		if !seen[key] {
+			seen[key] = true
+			candidates = append(candidates, command)
+		}
+	}
+	return candidates, nil
+}
+
+// alternativePrompt asks for a command different from the ones already found
+func alternativePrompt(prompt string, found []*ai.Command) string {
+	if len(found) == 0 {
+		return prompt
+	}
+
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nGive a different command than these alternatives:")
+	for _, c := range found {
+		fmt.Fprintf(&b, "\n- %s", c.Command)
+	}
+	return b.String()
+}
+
+// chooseCandidate lists the candidates and reads the user's choice: a number
+// picks one, "e" plus a number edits one, "r" asks for new candidates and "q"
+// quits. Enter picks the first candidate.
+func chooseCandidate(reader *bufio.Reader, candidates []*ai.Command) (command *ai.Command, regenerate bool, err error) {
+	fmt.Println("\nCandidates:")
+	for i, c := range candidates {
+		fmt.Printf("  %d) %s\n", i+1, formatCommand(c.Command))
+		if c.Explanation != "" {
+			fmt.Printf("     \033[2m%s\033[0m (%s)\n", c.Explanation, formatRisk(c.Risk))
+		}
+	}
+	fmt.Println()
+
+	for {
+		fmt.Printf("Pick [1-%d], e<N> to edit, r to regenerate, q to quit: ", len(candidates))
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, false, err
+		}
+		response = strings.ToLower(strings.TrimSpace(response))
+
+		switch response {
+		case "":
+			return candidates[0], false, nil
+		case "r", "regenerate":
+			return nil, true, nil
+		case "q", "quit", "n", "no":
+			fmt.Println("Cancelled")
+			return nil, false, nil
+		}
+
+		edit := strings.HasPrefix(response, "e")
+		i, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(response, "edit"), "e")))
+		if err != nil || i < 1 || i > len(candidates) {
+			fmt.Println("Invalid choice")
+			continue
+		}
+
+		picked := *candidates[i-1]
+		if edit {
+			edited, ok, err := editLine(picked.Command)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				fmt.Println("Cancelled")
+				return nil, false, nil
+			}
+			picked.Command = edited
+		}
+		return &picked, false, nil
+	}
+}
@@ -2,16 +2,32 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/storage"
 	"github.com/spf13/cobra"
 )
 
 var (
-	chatNoRepl bool
+	chatNoRepl       bool
+	chatImages       []string
+	chatTools        bool
+	chatTemplate     string
+	chatShowThinking bool
+	chatResume       string
+	chatContinue     bool
+
+	// chatDefaultOptions give conversational answers some variety
+	chatDefaultOptions = ai.Options{Temperature: ai.Float(0.7)}
 
 	chatCmd = &cobra.Command{
 		Use:   "chat [prompt]",
@@ -24,52 +40,123 @@ var (
 				initialPrompt = strings.Join(args, " ")
 			}
 
+			if len(chatImages) > 0 && initialPrompt == "" {
+				return fmt.Errorf("please provide a prompt to send with --image")
+			}
+			images, err := loadImages(chatImages)
+			if err != nil {
+				return err
+			}
+			initial := ai.Message{Role: ai.RoleUser, Content: initialPrompt, Images: images}
+
+			if !useDummy {
+				if err := ensureModelInstalled(cmd.Context(), chatModelName(), fallbackModels...); err != nil {
+					return err
+				}
+			}
+
 			// Check if --no-repl flag is set for single-shot mode
 			if chatNoRepl {
+				if chatResume != "" || chatContinue {
+					return fmt.Errorf("--resume and --continue need the REPL")
+				}
 				if initialPrompt == "" {
 					return fmt.Errorf("please provide a prompt for single-shot mode")
 				}
-				return handleChatPrompt(initialPrompt)
+				return handleChatPrompt(cmd.Context(), initial)
 			}
 
 			// Always REPL mode (default)
-			return runChatREPL(initialPrompt)
+			return runChatREPL(cmd.Context(), initial)
 		},
 	}
 )
 
 func init() {
 	chatCmd.Flags().BoolVar(&chatNoRepl, "no-repl", false, "Single-shot mode instead of REPL")
+	chatCmd.Flags().StringArrayVar(&chatImages, "image", nil, "Image file to send with the prompt, for vision models like llava (repeatable)")
+	chatCmd.Flags().StringVar(&chatTemplate, "prompt-template", "chat", "System prompt template (see: clai prompts)")
+	chatCmd.Flags().BoolVar(&chatShowThinking, "show-thinking", false, "Show the reasoning of thinking models like deepseek-r1 instead of folding it")
+	chatCmd.Flags().StringVar(&chatResume, "resume", "", "Resume the saved chat with this ID")
+	chatCmd.Flags().BoolVar(&chatContinue, "continue", false, "Continue the most recent chat")
+	chatCmd.Flags().BoolVar(&chatTools, "tools", false, "Let the model read files, list directories, grep and run shell commands (each call needs your approval)")
 	rootCmd.AddCommand(chatCmd)
 }
 
 // handleChatPrompt processes a single chat prompt (--no-repl mode)
-func handleChatPrompt(prompt string) error {
+func handleChatPrompt(ctx context.Context, prompt ai.Message) error {
 	if useDummy {
-		fmt.Printf("Dummy response to: %s\n", prompt)
+		fmt.Printf("Dummy response to: %s\n", prompt.Content)
 		return nil
 	}
 
-	client := ai.NewClient(aiModel)
-	response, err := client.Chat(prompt)
+	log := openChatLog()
+	defer log.close()
+
+	// Tool calls need the streaming loop of the REPL
+	if chatTools {
+		messages, err := chatTurn(ctx, nil, nil, prompt)
+		if err != nil {
+			return fmt.Errorf("failed to generate response: %w", err)
+		}
+		log.save(messages)
+		return nil
+	}
+
+	provider, err := newChatProvider(chatModelName(), generationOptions(chatDefaultOptions))
+	if err != nil {
+		return err
+	}
+	reply, err := provider.Chat(ctx, []ai.Message{prompt})
 	if err != nil {
 		return fmt.Errorf("failed to generate response: %w", err)
 	}
-	fmt.Println(response)
+	if chatShowThinking && reply.Thinking != "" {
+		fmt.Printf("\033[2m%s\033[0m\n\n", reply.Thinking)
+	}
+	fmt.Println(reply.Content)
+	printAnsweredBy(reply.Metrics, chatModelName())
+	printMetrics(reply.Metrics)
+	log.save([]ai.Message{prompt, {Role: ai.RoleAssistant, Content: reply.Content, Thinking: reply.Thinking}})
 	return nil
 }
 
 // runChatREPL starts the interactive chat REPL mode
-func runChatREPL(initialPrompt string) error {
+func runChatREPL(ctx context.Context, initial ai.Message) error {
 	scanner := bufio.NewScanner(os.Stdin)
-	fmt.Println("\033[2mclai chat - Type your messages ('exit' to quit)\033[0m")
+	fmt.Println("\033[2mclai chat - Type your messages ('exit' to quit, Ctrl+C stops an answer)\033[0m")
+
+	log := openChatLog()
+	defer log.close()
 
-	if initialPrompt != "" {
-		fmt.Printf("\033[1;34mYou:\033[0m %s\n", initialPrompt)
-		if err := validatePromptLength(initialPrompt); err != nil {
+	// Conversation history sent with every request so follow-ups have context,
+	// summarised once it outgrows the context window
+	var history []ai.Message
+	window, err := newChatWindow()
+	if err != nil {
+		return err
+	}
+	if chatResume != "" || chatContinue {
+		if history, err = log.resume(chatResume, window); err != nil {
+			return err
+		}
+	}
+
+	if initial.Content != "" {
+		fmt.Printf("\033[1;34mYou:\033[0m %s\n", initial.Content)
+		for _, path := range chatImages {
+			fmt.Printf("\033[2m[image: %s]\033[0m\n", path)
+		}
+		if err := validatePromptLength(initial.Content); err != nil {
 			fmt.Printf("\033[31m%v\033[0m\n", err)
-		} else if err := streamChatResponse(initialPrompt); err != nil {
-			fmt.Printf("\033[31mError: %v\033[0m\n", err)
+		} else {
+			next, err := chatTurn(ctx, window, history, initial)
+			if err != nil {
+				fmt.Printf("\033[31mError: %v\033[0m\n", err)
+			}
+			log.save(next[len(history):])
+			history = next
+			log.saveSummary(window, history)
 		}
 	}
 
@@ -92,30 +179,210 @@ func runChatREPL(initialPrompt string) error {
 			fmt.Printf("\033[31m%v\033[0m\n", err)
 			continue
 		}
-		if err := streamChatResponse(prompt); err != nil {
+		next, err := chatTurn(ctx, window, history, ai.Message{Role: ai.RoleUser, Content: prompt})
+		if err != nil {
 			fmt.Printf("\033[31mError: %v\033[0m\n", err)
 			continue
 		}
+		log.save(next[len(history):])
+		history = next
+		log.saveSummary(window, history)
 	}
 
 	return nil
 }
 
-// streamChatResponse streams the AI response
-func streamChatResponse(prompt string) error {
+// chatTurn sends the user's message with the conversation so far and returns the
+// history extended with the new turns, including any tool calls and their
+// results. Only what fits the window is sent. On error the history is returned
+// unchanged, and on Ctrl+C it keeps the rounds of tool calls already run.
+func chatTurn(ctx context.Context, window *chatWindow, history []ai.Message, prompt ai.Message) ([]ai.Message, error) {
+	// Ctrl+C while summarising, streaming or running tools stops only this turn
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	messages := append(history, prompt)
+	for round := 0; ; round++ {
+		sent, err := window.fit(ctx, messages)
+		var reply *ai.Reply
+		if err == nil {
+			reply, err = streamChatResponse(ctx, sent)
+		}
+		if errors.Is(err, context.Canceled) {
+			fmt.Println("\033[2m(interrupted)\033[0m")
+			if round == 0 {
+				return history, nil
+			}
+			return messages, nil
+		}
+		if err != nil {
+			return history, err
+		}
+
+		messages = append(messages, ai.Message{Role: ai.RoleAssistant, Content: reply.Content, Thinking: reply.Thinking, ToolCalls: reply.ToolCalls})
+		if len(reply.ToolCalls) == 0 {
+			return messages, nil
+		}
+		if round >= maxToolRounds {
+			fmt.Printf("\033[2m(stopped after %d rounds of tool calls)\033[0m\n", maxToolRounds)
+			return messages, nil
+		}
+		messages = append(messages, runToolCalls(ctx, reply.ToolCalls)...)
+		if ctx.Err() != nil {
+			fmt.Println("\033[2m(interrupted)\033[0m")
+			return messages, nil
+		}
+	}
+}
+
+// streamChatResponse streams the AI response to the conversation and returns the full reply
+func streamChatResponse(ctx context.Context, messages []ai.Message) (*ai.Reply, error) {
 	if useDummy {
-		fmt.Printf("\n\033[1;32mAI:\033[0m Dummy response to: %s\n", prompt)
-		return nil
+		response := fmt.Sprintf("Dummy response to: %s", messages[len(messages)-1].Content)
+		fmt.Printf("\n\033[1;32mAI:\033[0m %s\n", response)
+		return &ai.Reply{Content: response}, nil
 	}
 
-	client := ai.NewClient(aiModel)
-	fmt.Print("\n\033[1;32mAI:\033[0m ")
+	provider, err := newChatProvider(chatModelName(), generationOptions(chatDefaultOptions))
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println()
+
+	// Reasoning comes first and is folded into one line unless --show-thinking
+	thinking := &thinkingView{expand: chatShowThinking}
+	ctx = ai.WithThinking(ctx, thinking.write)
 
-	err := client.ChatStream(prompt, func(chunk string) error {
+	answering := false
+	printChunk := func(chunk string) error {
+		if !answering {
+			thinking.finish()
+			fmt.Print("\033[1;32mAI:\033[0m ")
+			answering = true
+		}
 		fmt.Print(chunk)
 		return nil
-	})
+	}
 
+	var reply *ai.Reply
+	if chatTools {
+		toolCaller, ok := provider.(ai.ToolCaller)
+		if !ok {
+			return nil, fmt.Errorf("provider %q does not support tool calling", aiProvider)
+		}
+		reply, err = toolCaller.ChatStreamTools(ctx, messages, toolDefinitions(), printChunk)
+	} else {
+		reply, err = provider.ChatStream(ctx, messages, printChunk)
+	}
+
+	if !answering {
+		printChunk("")
+	}
 	fmt.Println()
-	return err
+	if err != nil {
+		return nil, err
+	}
+	printAnsweredBy(reply.Metrics, chatModelName())
+	printMetrics(reply.Metrics)
+	return reply, nil
+}
+
+// loadImages reads the image files given with --image
+func loadImages(paths []string) ([][]byte, error) {
+	var images [][]byte
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read image: %w", err)
+		}
+		if mimeType := http.DetectContentType(data); !strings.HasPrefix(mimeType, "image/") {
+			return nil, fmt.Errorf("%s is not an image (%s)", path, mimeType)
+		}
+		images = append(images, data)
+	}
+	return images, nil
+}
+
+// chatLog saves a CLI conversation to the database so it can be reopened in
+// the web UI. A nil chatLog, used when the database can't be opened, saves nothing.
+type chatLog struct {
+	store  *storage.Store
+	chatID string
+}
+
+// openChatLog opens the database quietly, warning when it isn't available.
+// Dummy conversations aren't saved.
+func openChatLog() *chatLog {
+	if useDummy {
+		return nil
+	}
+	store, err := storage.OpenStore(io.Discard)
+	if err != nil {
+		fmt.Printf("\033[2mWarning: conversation won't be saved: %v\033[0m\n", err)
+		return nil
+	}
+	return &chatLog{store: store}
+}
+
+// save appends messages to the conversation, creating the chat on first use
+func (l *chatLog) save(messages []ai.Message) {
+	if l == nil || len(messages) == 0 {
+		return
+	}
+
+	if l.chatID == "" {
+		now := time.Now()
+		chat := &storage.Chat{ID: storage.NewID(), Title: chatTitle(messages[0].Content), CreatedAt: now, UpdatedAt: now}
+		if err := l.store.CreateChat(chat); err != nil {
+			fmt.Printf("\033[2mWarning: failed to save conversation: %v\033[0m\n", err)
+			return
+		}
+		l.chatID = chat.ID
+	}
+
+	for _, msg := range messages {
+		stored, err := storage.FromAIMessage(l.chatID, msg)
+		if err == nil {
+			for _, m := range stored {
+				if err = l.store.CreateMessage(m); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			fmt.Printf("\033[2mWarning: failed to save message: %v\033[0m\n", err)
+			return
+		}
+	}
+}
+
+// close closes the database
+func (l *chatLog) close() {
+	if l != nil {
+		l.store.Close()
+	}
+}
+
+// chatTitle derives a chat title from its first message
+func chatTitle(prompt string) string {
+	const maxTitle = 50
+
+	title := strings.Join(strings.Fields(prompt), " ")
+	if runes := []rune(title); len(runes) > maxTitle {
+		title = string(runes[:maxTitle-1]) + "…"
+	}
+	if title == "" {
+		title = "New Chat"
+	}
+	return title
+}
+
+// newChatProvider returns a provider with the chat system prompt that falls
+// back on the --fallback-models
+func newChatProvider(model string, opts ai.Options) (ai.Provider, error) {
+	system, err := renderSystemPrompt(chatTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return newRoutedProvider(model, opts, system)
 }
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/ai/aitest"
+)
+
+// withStdin feeds input to code reading os.Stdin, such as the approval prompts
+func withStdin(t *testing.T, input string) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(input)
+	w.Close()
+
+	prev := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = prev
+		r.Close()
+	})
+}
+
+func TestChatTurnStreams(t *testing.T) {
+	srv := useFakeOllama(t)
+	srv.Enqueue(aitest.Response{Chunks: []string{"Use ", "ls -la"}})
+
+	history := []ai.Message{{Role: ai.RoleUser, Content: "hi"}, {Role: ai.RoleAssistant, Content: "Hello!"}}
+	messages, err := chatTurn(context.Background(), nil, history, ai.Message{Role: ai.RoleUser, Content: "how do I list files?"})
+	if err != nil {
+		t.Fatalf("chatTurn: %v", err)
+	}
+
+	if got := messages[len(messages)-1]; got.Role != ai.RoleAssistant || got.Content != "Use ls -la" {
+		t.Errorf("last message = %+v, want the streamed reply", got)
+	}
+
+	// The model sees the system prompt and the earlier turns
+	sent := srv.Requests()[0].Messages
+	if len(sent) != 4 || sent[0].Role != ai.RoleSystem || sent[1].Content != "hi" {
+		t.Errorf("sent messages = %+v, want system prompt, history and prompt", sent)
+	}
+}
+
+func TestChatTurnKeepsThinkingApart(t *testing.T) {
+	srv := useFakeOllama(t)
+	srv.Enqueue(aitest.Response{Chunks: []string{"<think>Files?</think>", "Use ls"}})
+
+	messages, err := chatTurn(context.Background(), nil, nil, ai.Message{Role: ai.RoleUser, Content: "how do I list files?"})
+	if err != nil {
+		t.Fatalf("chatTurn: %v", err)
+	}
+	if got := messages[len(messages)-1]; got.Content != "Use ls" || got.Thinking != "Files?" {
+		t.Errorf("last message = %+v, want the answer with its thinking apart", got)
+	}
+}
+
+func TestChatTurnWithTools(t *testing.T) {
+	srv := useFakeOllama(t)
+	prevTools := chatTools
+	chatTools = true
+	t.Cleanup(func() { chatTools = prevTools })
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Enqueue(
+		aitest.Response{ToolCalls: []ai.ToolCall{{Function: ai.ToolCallFunction{
+			Name:      "list_dir",
+			Arguments: map[string]interface{}{"path": dir},
+		}}}},
+		aitest.Response{Content: "There is one file, notes.txt."},
+	)
+	withStdin(t, "y\n")
+
+	messages, err := chatTurn(context.Background(), nil, nil, ai.Message{Role: ai.RoleUser, Content: "what's in there?"})
+	if err != nil {
+		t.Fatalf("chatTurn: %v", err)
+	}
+
+	roles := make([]string, len(messages))
+	for i, msg := range messages {
+		roles[i] = msg.Role
+	}
+	if got := strings.Join(roles, ","); got != "user,assistant,tool,assistant" {
+		t.Fatalf("roles = %s, want user,assistant,tool,assistant", got)
+	}
+	if result := messages[2]; result.ToolName != "list_dir" || !strings.Contains(result.Content, "notes.txt") {
+		t.Errorf("tool result = %+v, want the directory listing", result)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 2 || len(requests[0].Tools) != len(localTools) {
+		t.Fatalf("requests = %+v, want two requests offering the local tools", requests)
+	}
+	if last := requests[1].Messages[len(requests[1].Messages)-1]; last.Role != ai.RoleTool {
+		t.Errorf("second request ends with %+v, want the tool result", last)
+	}
+}
+
+func TestChatTurnInterruptsTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts can't be sent to the own process on Windows")
+	}
+	srv := useFakeOllama(t)
+	prevTools := chatTools
+	chatTools = true
+	t.Cleanup(func() { chatTools = prevTools })
+
+	// The command presses Ctrl+C for the user while it runs
+	srv.Enqueue(aitest.Response{ToolCalls: []ai.ToolCall{
+		{Function: ai.ToolCallFunction{Name: "run_shell", Arguments: map[string]interface{}{"command": "kill -INT $PPID; exec sleep 10"}}},
+		{Function: ai.ToolCallFunction{Name: "list_dir", Arguments: map[string]interface{}{"path": "."}}},
+	}})
+	withStdin(t, "y\ny\n")
+
+	history := []ai.Message{{Role: ai.RoleUser, Content: "hi"}, {Role: ai.RoleAssistant, Content: "Hello!"}}
+	var messages []ai.Message
+	var err error
+	out := captureStdout(t, func() {
+		messages, err = chatTurn(context.Background(), nil, history, ai.Message{Role: ai.RoleUser, Content: "wait a bit"})
+	})
+	if err != nil {
+		t.Fatalf("chatTurn: %v", err)
+	}
+	if !strings.Contains(out, "(interrupted)") {
+		t.Errorf("chatTurn printed %q, want the turn interrupted", out)
+	}
+
+	// The round already run is kept, with a result for every call
+	roles := make([]string, len(messages))
+	for i, msg := range messages {
+		roles[i] = msg.Role
+	}
+	if got := strings.Join(roles, ","); got != "user,assistant,user,assistant,tool,tool" {
+		t.Fatalf("roles = %s, want the history, the prompt, the tool calls and their results", got)
+	}
+	for _, result := range messages[4:] {
+		if !strings.Contains(result.Content, "interrupted") {
+			t.Errorf("tool result = %+v, want it interrupted", result)
+		}
+	}
+	if len(srv.Requests()) != 1 {
+		t.Errorf("requests = %d, want no answer asked for after the interrupt", len(srv.Requests()))
+	}
+}
+
+func TestChatWindowResumes(t *testing.T) {
+	useFakeOllama(t)
+
+	var history []ai.Message
+	for i := 0; i < 12; i++ {
+		role := ai.RoleUser
+		if i%2 == 1 {
+			role = ai.RoleAssistant
+		}
+		history = append(history, ai.Message{Role: role, Content: strings.Repeat("word ", 100)})
+	}
+
+	log := openChatLog()
+	defer log.close()
+	window := &chatWindow{manager: &ai.ContextManager{MaxTokens: 1000}}
+
+	sent, err := window.fit(context.Background(), history)
+	if err != nil {
+		t.Fatalf("fit: %v", err)
+	}
+	if window.summarized == 0 || len(sent) != len(history)-window.summarized {
+		t.Fatalf("sent %d messages after leaving out %d, want old ones left out", len(sent), window.summarized)
+	}
+	log.save(history)
+	log.saveSummary(window, history)
+
+	// A later session picks up where this one left off
+	resumed := &chatWindow{manager: window.manager}
+	later := openChatLog()
+	defer later.close()
+	restored, err := later.resume("", resumed)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if len(restored) != len(history) || resumed.summarized != window.summarized {
+		t.Errorf("resumed %d messages with %d left out, want %d with %d", len(restored), resumed.summarized, len(history), window.summarized)
+	}
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/storage"
+)
+
+var contextStrategy string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&contextStrategy, "context-strategy", ai.ContextSummarize,
+		"What to do with old turns of chats that outgrow the context window: summarize or drop")
+}
+
+// contextTokens returns the context window chats are kept within: --num-ctx if set
+func contextTokens() int {
+	if rootCmd.PersistentFlags().Changed("num-ctx") {
+		return optNumCtx
+	}
+	return ai.DefaultContextTokens
+}
+
+// summarizeContext reports whether --context-strategy asks for old turns to be summarised
+func summarizeContext() (bool, error) {
+	switch contextStrategy {
+	case ai.ContextSummarize:
+		return true, nil
+	case ai.ContextDrop:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown context strategy %q (expected %q or %q)", contextStrategy, ai.ContextSummarize, ai.ContextDrop)
+	}
+}
+
+// chatWindow keeps a CLI conversation within the model's context window,
+// remembering which of its first messages the rolling summary stands for.
+// A nil chatWindow sends the whole conversation.
+type chatWindow struct {
+	manager    *ai.ContextManager
+	summary    string
+	summarized int // Messages at the start of the history left out or summarised
+	saved      int // summarized when the summary was last saved
+}
+
+// newChatWindow returns the window for --context-strategy, summarising with the chat model
+func newChatWindow() (*chatWindow, error) {
+	if useDummy {
+		return nil, nil
+	}
+	summarize, err := summarizeContext()
+	if err != nil {
+		return nil, err
+	}
+
+	manager := &ai.ContextManager{MaxTokens: contextTokens()}
+	if summarize {
+		manager.Summarizer, err = newRoutedProvider(chatModelName(), generationOptions(ai.Options{Temperature: ai.Float(0)}), "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &chatWindow{manager: manager}, nil
+}
+
+// fit returns the messages of history to send, telling the user when older
+// ones are summarised or left out
+func (w *chatWindow) fit(ctx context.Context, history []ai.Message) ([]ai.Message, error) {
+	if w == nil {
+		return history, nil
+	}
+
+	window, err := w.manager.Fit(ctx, w.summary, w.summarized, history)
+	if err != nil {
+		return nil, err
+	}
+	if n := window.Summarized - w.summarized; n > 0 {
+		if window.Summary != w.summary {
+			fmt.Printf("\033[2m(summarised %d earlier messages to fit the context window)\033[0m\n", n)
+		} else {
+			fmt.Printf("\033[2m(left out %d earlier messages to fit the context window)\033[0m\n", n)
+		}
+	}
+	w.summary, w.summarized = window.Summary, window.Summarized
+	return window.Messages, nil
+}
+
+// saveSummary stores the window's summary of history with the chat, so the
+// web UI and later sessions resume with the same context
+func (l *chatLog) saveSummary(w *chatWindow, history []ai.Message) {
+	if l == nil || w == nil || l.chatID == "" || w.summarized == w.saved {
+		return
+	}
+
+	err := l.store.SaveChatSummary(&storage.ChatSummary{
+		ChatID:       l.chatID,
+		Summary:      w.summary,
+		MessageCount: storage.StoredCount(history[:w.summarized]),
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		fmt.Printf("\033[2mWarning: failed to save conversation summary: %v\033[0m\n", err)
+		return
+	}
+	w.saved = w.summarized
+}
+
+// resume continues the saved chat chatID, or the latest chat if chatID is
+// empty, returning its messages and restoring its summary into w
+func (l *chatLog) resume(chatID string, w *chatWindow) ([]ai.Message, error) {
+	if l == nil {
+		return nil, fmt.Errorf("saved chats are not available")
+	}
+
+	if chatID == "" {
+		chats, err := l.store.ListChats()
+		if err != nil {
+			return nil, err
+		}
+		if len(chats) == 0 {
+			return nil, fmt.Errorf("there is no chat to continue")
+		}
+		chatID = chats[0].ID
+	}
+
+	chat, stored, err := l.store.GetChatWithMessages(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat == nil {
+		return nil, fmt.Errorf("chat %s not found", chatID)
+	}
+	l.chatID = chat.ID
+	history := storage.ToAIMessages(stored)
+
+	if w != nil {
+		summary, err := l.store.GetChatSummary(chat.ID)
+		if err != nil {
+			return nil, err
+		}
+		if summary != nil && summary.MessageCount <= len(history) {
+			w.summary, w.summarized, w.saved = summary.Summary, summary.MessageCount, summary.MessageCount
+		}
+	}
+
+	fmt.Printf("\033[2mResuming \"%s\" (%d messages)\033[0m\n", chat.Title, len(history))
+	return history, nil
+}
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/misrab/clai/internal/ai"
+	"github.com/spf13/cobra"
+)
+
+var (
+	modelsCmd = &cobra.Command{
+		Use:   "models",
+		Short: "Manage local AI models",
+		Long:  "List, download, inspect and remove the models available to clai.",
+	}
+
+	modelsListCmd = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List installed models",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listModels(cmd.Context())
+		},
+	}
+
+	modelsPullCmd = &cobra.Command{
+		Use:   "pull <name>",
+		Short: "Download a model",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manager, err := newModelManager()
+			if err != nil {
+				return err
+			}
+			return pullModel(cmd.Context(), manager, args[0])
+		},
+	}
+
+	modelsShowCmd = &cobra.Command{
+		Use:   "show [name]",
+		Short: "Show details of a model (defaults to the chat model)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := chatModelName()
+			if len(args) > 0 {
+				name = args[0]
+			}
+			return showModel(cmd.Context(), name)
+		},
+	}
+
+	modelsRemoveCmd = &cobra.Command{
+		Use:     "rm <name>",
+		Aliases: []string{"remove", "delete"},
+		Short:   "Remove a model",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manager, err := newModelManager()
+			if err != nil {
+				return err
+			}
+			if err := manager.DeleteModel(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("✓ Removed %s\n", args[0])
+			return nil
+		},
+	}
+)
+
+func init() {
+	modelsCmd.AddCommand(modelsListCmd, modelsPullCmd, modelsShowCmd, modelsRemoveCmd)
+	rootCmd.AddCommand(modelsCmd)
+}
+
+// newModelManager returns the configured provider if it can manage models
+func newModelManager() (ai.ModelManager, error) {
+	provider, err := newProvider("", ai.Options{}, "")
+	if err != nil {
+		return nil, err
+	}
+	manager, ok := provider.(ai.ModelManager)
+	if !ok {
+		return nil, fmt.Errorf("provider %q does not support managing models", aiProvider)
+	}
+	return manager, nil
+}
+
+// listModels prints the installed models as a table
+func listModels(ctx context.Context) error {
+	provider, err := newProvider("", ai.Options{}, "")
+	if err != nil {
+		return err
+	}
+
+	models, err := provider.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(models) == 0 {
+		fmt.Println("No models installed. Download one with: clai models pull <name>")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE\tMODIFIED")
+	for _, m := range models {
+		modified := ""
+		if !m.ModifiedAt.IsZero() {
+			modified = m.ModifiedAt.Format("2006-01-02 15:04")
+		}
+		size := ""
+		if m.Size > 0 {
+			size = formatBytes(m.Size)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", m.Name, size, modified)
+	}
+	return w.Flush()
+}
+
+// pullModel downloads a model, drawing a progress bar for each layer
+func pullModel(ctx context.Context, manager ai.ModelManager, name string) error {
+	fmt.Printf("Pulling %s...\n", name)
+
+	lastStatus := ""
+	err := manager.PullModel(ctx, name, func(p ai.PullProgress) {
+		// Each status (e.g. one per layer) gets its own line
+		changed := p.Status != lastStatus
+		if changed && lastStatus != "" {
+			fmt.Println()
+		}
+		lastStatus = p.Status
+
+		if p.Total > 0 {
+			fmt.Printf("\r\033[K%s", formatProgress(p))
+		} else if changed {
+			fmt.Print(p.Status)
+		}
+	})
+	fmt.Println()
+
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Pulled %s\n", name)
+	return nil
+}
+
+// showModel prints the details of a model
+func showModel(ctx context.Context, name string) error {
+	manager, err := newModelManager()
+	if err != nil {
+		return err
+	}
+
+	info, err := manager.ShowModel(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Model:\t%s\n", info.Name)
+	if info.Family != "" {
+		fmt.Fprintf(w, "Family:\t%s\n", info.Family)
+	}
+	if info.ParameterSize != "" {
+		fmt.Fprintf(w, "Parameters:\t%s\n", info.ParameterSize)
+	}
+	if info.QuantizationLevel != "" {
+		fmt.Fprintf(w, "Quantization:\t%s\n", info.QuantizationLevel)
+	}
+	if info.ContextLength > 0 {
+		fmt.Fprintf(w, "Context length:\t%d\n", info.ContextLength)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if info.Parameters != "" {
+		fmt.Printf("\nParameters:\n%s\n", indent(info.Parameters))
+	}
+	if info.Template != "" {
+		fmt.Printf("\nTemplate:\n%s\n", indent(info.Template))
+	}
+	return nil
+}
+
+// ensureModelInstalled offers to pull the model when the provider can manage
+// models and it isn't installed yet, unless one of the fallbacks is installed.
+// Failures to check are ignored so the request itself reports the real problem.
+func ensureModelInstalled(ctx context.Context, model string, fallbacks ...string) error {
+	provider, err := newProvider(model, ai.Options{}, "")
+	if err != nil {
+		return err
+	}
+	manager, ok := provider.(ai.ModelManager)
+	if !ok {
+		return nil
+	}
+
+	models, err := provider.ListModels(ctx)
+	if err != nil || ai.HasModel(models, model) {
+		return nil
+	}
+	for _, fallback := range fallbacks {
+		if ai.HasModel(models, fallback) {
+			fmt.Printf("\033[2mModel '%s' is not installed, falling back on %s\033[0m\n", model, fallback)
+			return nil
+		}
+	}
+
+	fmt.Printf("Model '%s' is not installed. Pull it now? [Y/n] ", model)
+	response, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("model '%s' not installed. Download it with:\n  clai models pull %s", model, model)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(response)) {
+	case "", "y", "yes":
+		return pullModel(ctx, manager, model)
+	default:
+		return fmt.Errorf("model '%s' not installed. Download it with:\n  clai models pull %s", model, model)
+	}
+}
+
+// formatProgress renders a single-line progress bar for a download
+func formatProgress(p ai.PullProgress) string {
+	const width = 30
+
+	ratio := float64(p.Completed) / float64(p.Total)
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * width)
+
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+
+	return fmt.Sprintf("%s [%s] %3.0f%% %s/%s",
+		p.Status, bar, ratio*100, formatBytes(p.Completed), formatBytes(p.Total))
+}
+
+// formatBytes renders a byte count with a binary unit suffix
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// indent prefixes every line of s with two spaces
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/ai/aitest"
+)
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = prev }()
+
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+
+	fn()
+	w.Close()
+	return <-done
+}
+
+// runClai runs the root command with args, returning its output and error
+func runClai(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	// Errors are returned to the test instead of printed with the usage
+	rootCmd.SilenceErrors, rootCmd.SilenceUsage = true, true
+	defer func() { rootCmd.SilenceErrors, rootCmd.SilenceUsage = false, false }()
+
+	var err error
+	out := captureStdout(t, func() {
+		rootCmd.SetArgs(args)
+		err = rootCmd.Execute()
+	})
+	rootCmd.SetArgs(nil)
+	return out, err
+}
+
+func TestModelsCommands(t *testing.T) {
+	srv := useFakeOllama(t)
+
+	out, err := runClai(t, "models", "list")
+	if err != nil {
+		t.Fatalf("models list: %v", err)
+	}
+	if !strings.Contains(out, aitest.DefaultModel) || !strings.Contains(out, "1.0 GiB") {
+		t.Errorf("models list printed %q, want the installed model and its size", out)
+	}
+
+	out, err = runClai(t, "models", "pull", "tiny:1b")
+	if err != nil {
+		t.Fatalf("models pull: %v", err)
+	}
+	if !strings.Contains(out, "100%") || !strings.Contains(out, "✓ Pulled tiny:1b") {
+		t.Errorf("models pull printed %q, want the progress and a confirmation", out)
+	}
+
+	out, err = runClai(t, "models", "show", "tiny:1b")
+	if err != nil {
+		t.Fatalf("models show: %v", err)
+	}
+	for _, want := range []string{"Family:", "aitest", "Quantization:", "Q4_0", "Context length:", "4096", "temperature 0.7"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("models show printed %q, want it to contain %q", out, want)
+		}
+	}
+
+	if _, err := runClai(t, "models", "rm", "tiny:1b"); err != nil {
+		t.Fatalf("models rm: %v", err)
+	}
+	if ai.HasModel(srv.Models, "tiny:1b") {
+		t.Errorf("models = %+v, want tiny:1b removed", srv.Models)
+	}
+
+	_, err = runClai(t, "models", "rm", "tiny:1b")
+	if !errors.Is(err, ai.ErrModelNotFound) {
+		t.Errorf("removing a missing model = %v, want ErrModelNotFound", err)
+	}
+	_, err = runClai(t, "models", "show", "missing:7b")
+	if !errors.Is(err, ai.ErrModelNotFound) {
+		t.Errorf("showing a missing model = %v, want ErrModelNotFound", err)
+	}
+
+	var paths []string
+	for _, req := range srv.Requests() {
+		paths = append(paths, req.Path)
+	}
+	want := "/api/pull /api/show /api/delete /api/delete /api/show"
+	if got := strings.Join(paths, " "); got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+}
+
+func TestPullModelErrors(t *testing.T) {
+	srv := useFakeOllama(t)
+	manager, err := newModelManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Enqueue(aitest.Response{StreamError: "disk full"})
+	captureStdout(t, func() { err = pullModel(context.Background(), manager, "tiny:1b") })
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("pullModel = %v, want the error sent mid-stream", err)
+	}
+
+	srv.Enqueue(aitest.Response{Status: http.StatusInternalServerError, Error: "registry unreachable"})
+	captureStdout(t, func() { err = pullModel(context.Background(), manager, "tiny:1b") })
+	if !errors.Is(err, ai.ErrServer) {
+		t.Errorf("pullModel = %v, want ErrServer", err)
+	}
+
+	if ai.HasModel(srv.Models, "tiny:1b") {
+		t.Errorf("models = %+v, want nothing installed by failed pulls", srv.Models)
+	}
+}
+
+func TestEnsureModelInstalled(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     string
+		fallbacks []string
+		input     string
+		wantErr   bool
+		wantPull  bool
+	}{
+		{name: "installed", model: aitest.DefaultModel},
+		{name: "installed without tag", model: "aitest"},
+		{name: "fallback installed", model: "missing:7b", fallbacks: []string{aitest.DefaultModel}},
+		{name: "pull on enter", model: "missing:7b", input: "\n", wantPull: true},
+		{name: "pull on yes", model: "missing:7b", input: "yes\n", wantPull: true},
+		{name: "declined", model: "missing:7b", input: "n\n", wantErr: true},
+		{name: "no answer", model: "missing:7b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := useFakeOllama(t)
+			withStdin(t, tt.input)
+
+			var err error
+			captureStdout(t, func() { err = ensureModelInstalled(context.Background(), tt.model, tt.fallbacks...) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ensureModelInstalled = %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "clai models pull "+tt.model) {
+				t.Errorf("error = %q, want the pull command", err)
+			}
+
+			pulled := false
+			for _, req := range srv.Requests() {
+				pulled = pulled || req.Path == "/api/pull"
+			}
+			if pulled != tt.wantPull {
+				t.Errorf("pulled = %v, want %v", pulled, tt.wantPull)
+			}
+			if tt.wantPull && !ai.HasModel(srv.Models, tt.model) {
+				t.Errorf("models = %+v, want %s installed", srv.Models, tt.model)
+			}
+		})
+	}
+}
+
+func TestFormatProgress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		progress ai.PullProgress
+		expected string
+	}{
+		{
+			progress: ai.PullProgress{Status: "pulling abc", Total: 4 << 20},
+			expected: "pulling abc [>                             ]   0% 0 B/4.0 MiB",
+		},
+		{
+			progress: ai.PullProgress{Status: "pulling abc", Total: 4 << 20, Completed: 1 << 20},
+			expected: "pulling abc [=======>                      ]  25% 1.0 MiB/4.0 MiB",
+		},
+		{
+			progress: ai.PullProgress{Status: "pulling abc", Total: 2 << 30, Completed: 2 << 30},
+			expected: "pulling abc [==============================] 100% 2.0 GiB/2.0 GiB",
+		},
+		{
+			// Ollama can report more than the total while it finishes a layer
+			progress: ai.PullProgress{Status: "pulling abc", Total: 1000, Completed: 1500},
+			expected: "pulling abc [==============================] 100% 1.5 KiB/1000 B",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := formatProgress(tt.progress); got != tt.expected {
+			t.Errorf("formatProgress(%+v) = %q, want %q", tt.progress, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/misrab/clai/internal/ai"
+)
+
+var (
+	optTemperature float64
+	optTopP        float64
+	optTopK        int
+	optSeed        int
+	optNumCtx      int
+	optNumPredict  int
+	optStop        []string
+)
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.Float64Var(&optTemperature, "temperature", 0, "Sampling temperature (default depends on the subcommand)")
+	flags.Float64Var(&optTopP, "top-p", 0, "Nucleus sampling probability")
+	flags.IntVar(&optTopK, "top-k", 0, "Sample only from the top K tokens")
+	flags.IntVar(&optSeed, "seed", 0, "Random seed for reproducible output")
+	flags.IntVar(&optNumCtx, "num-ctx", 0, "Context window size in tokens")
+	flags.IntVar(&optNumPredict, "num-predict", 0, "Maximum number of tokens to generate")
+	flags.StringArrayVar(&optStop, "stop", nil, "Stop sequence (repeatable)")
+}
+
+// flagOptions returns the generation options set explicitly on the command line
+func flagOptions() ai.Options {
+	flags := rootCmd.PersistentFlags()
+
+	var opts ai.Options
+	if flags.Changed("temperature") {
+		opts.Temperature = ai.Float(optTemperature)
+	}
+	if flags.Changed("top-p") {
+		opts.TopP = ai.Float(optTopP)
+	}
+	if flags.Changed("top-k") {
+		opts.TopK = ai.Int(optTopK)
+	}
+	if flags.Changed("seed") {
+		opts.Seed = ai.Int(optSeed)
+	}
+	if flags.Changed("num-ctx") {
+		opts.NumCtx = ai.Int(optNumCtx)
+	}
+	if flags.Changed("num-predict") {
+		opts.NumPredict = ai.Int(optNumPredict)
+	}
+	if flags.Changed("stop") {
+		opts.Stop = optStop
+	}
+	return opts
+}
+
+// generationOptions applies the command-line options over a subcommand's defaults
+func generationOptions(defaults ai.Options) ai.Options {
+	return defaults.Merge(flagOptions())
+}
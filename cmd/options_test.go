@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/misrab/clai/internal/ai"
+)
+
+// setOptionFlags sets generation flags as if given on the command line,
+// resetting them when the test ends
+func setOptionFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+
+	flags := rootCmd.PersistentFlags()
+	t.Cleanup(func() {
+		for name := range values {
+			flags.Lookup(name).Changed = false
+		}
+		optTemperature, optTopP, optTopK, optSeed, optNumCtx, optNumPredict, optStop = 0, 0, 0, 0, 0, 0, nil
+	})
+	for name, value := range values {
+		if err := flags.Set(name, value); err != nil {
+			t.Fatalf("set --%s: %v", name, err)
+		}
+	}
+}
+
+func TestGenerationOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   map[string]string
+		request ai.Options // Options sent by the web UI
+		bash    string     // Expected options as JSON
+		chat    string
+		web     string
+	}{
+		{
+			name: "defaults",
+			bash: `{"temperature":0}`,
+			chat: `{"temperature":0.7}`,
+			web:  `{"temperature":0.7}`,
+		},
+		{
+			name:  "temperature flag",
+			flags: map[string]string{"temperature": "0.3"},
+			bash:  `{"temperature":0.3}`,
+			chat:  `{"temperature":0.3}`,
+			web:   `{"temperature":0.3}`,
+		},
+		{
+			// An explicit 0 is set, unlike the flag's zero default
+			name:  "zero temperature flag",
+			flags: map[string]string{"temperature": "0"},
+			bash:  `{"temperature":0}`,
+			chat:  `{"temperature":0}`,
+			web:   `{"temperature":0}`,
+		},
+		{
+			name:  "other flags keep the defaults",
+			flags: map[string]string{"seed": "42", "num-ctx": "8192", "stop": "END"},
+			bash:  `{"temperature":0,"seed":42,"num_ctx":8192,"stop":["END"]}`,
+			chat:  `{"temperature":0.7,"seed":42,"num_ctx":8192,"stop":["END"]}`,
+			web:   `{"temperature":0.7,"seed":42,"num_ctx":8192,"stop":["END"]}`,
+		},
+		{
+			name:    "web request over flags",
+			flags:   map[string]string{"temperature": "0.3", "top-k": "20"},
+			request: ai.Options{Temperature: ai.Float(1.2), NumPredict: ai.Int(256)},
+			bash:    `{"temperature":0.3,"top_k":20}`,
+			chat:    `{"temperature":0.3,"top_k":20}`,
+			web:     `{"temperature":1.2,"top_k":20,"num_predict":256}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptionFlags(t, tt.flags)
+
+			got := map[string]ai.Options{
+				"bash": generationOptions(bashDefaultOptions),
+				"chat": generationOptions(chatDefaultOptions),
+				"web":  webGenerationOptions(tt.request),
+			}
+			want := map[string]string{"bash": tt.bash, "chat": tt.chat, "web": tt.web}
+			for name, opts := range got {
+				if data, _ := json.Marshal(opts); string(data) != want[name] {
+					t.Errorf("%s options = %s, want %s", name, data, want[name])
+				}
+			}
+		})
+	}
+}
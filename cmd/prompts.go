@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/misrab/clai/internal/prompts"
+	"github.com/spf13/cobra"
+)
+
+var (
+	systemPrompt string
+
+	promptsCmd = &cobra.Command{
+		Use:   "prompts",
+		Short: "Show the system prompt templates",
+		Long: `System prompts are Go text/template files. Put <name>.tmpl in the prompts
+directory to override a built-in template or add a new one, and pick it with
+--prompt-template. Templates can use {{.OS}}, {{.Arch}}, {{.Shell}}, {{.Cwd}},
+{{.User}}, {{.Date}}, {{.Coreutils}}, {{.GitBranch}}, {{.GitDirty}}, {{.Tools}}
+and {{.MissingTools}}, and {{join .Tools ", "}} to list tools.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := prompts.Dir()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Prompts directory: %s\n", dir)
+			fmt.Println("Built-in templates: bash, chat (see: clai prompts show <name>)")
+			return nil
+		},
+	}
+
+	promptsShowRendered bool
+
+	promptsShowCmd = &cobra.Command{
+		Use:   "show <name>",
+		Short: "Print a template's source, or with --render the resulting prompt",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, err := prompts.Load(args[0])
+			if err != nil {
+				return err
+			}
+			if promptsShowRendered {
+				if source, err = prompts.Render(source, prompts.CurrentVars()); err != nil {
+					return err
+				}
+			}
+			fmt.Println(source)
+			return nil
+		},
+	}
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&systemPrompt, "system", "", "System prompt to use instead of the subcommand's template (may use template variables)")
+	promptsShowCmd.Flags().BoolVar(&promptsShowRendered, "render", false, "Render the template with the current environment")
+	promptsCmd.AddCommand(promptsShowCmd)
+	rootCmd.AddCommand(promptsCmd)
+}
+
+// printEnvironment prints the environment bash describes to the model
+func printEnvironment(vars prompts.Vars) {
+	git := "not a git repository"
+	if vars.GitBranch != "" {
+		git = "branch " + vars.GitBranch
+		if vars.GitDirty {
+			git += ", uncommitted changes"
+		}
+	}
+	coreutils := vars.Coreutils
+	if coreutils == "" {
+		coreutils = "unknown"
+	}
+
+	fmt.Printf("OS:        %s/%s\n", vars.OS, vars.Arch)
+	fmt.Printf("Shell:     %s\n", vars.Shell)
+	fmt.Printf("Coreutils: %s\n", coreutils)
+	fmt.Printf("Directory: %s\n", vars.Cwd)
+	fmt.Printf("Git:       %s\n", git)
+	fmt.Printf("User:      %s\n", vars.User)
+	fmt.Printf("Tools:     %s\n", listOrNone(vars.Tools))
+	fmt.Printf("Missing:   %s\n", listOrNone(vars.MissingTools))
+}
+
+// listOrNone joins items with commas, or returns "none"
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
+
+// renderSystemPrompt renders --system if given, otherwise the named template
+func renderSystemPrompt(template string) (string, error) {
+	source, err := loadSystemPrompt(template)
+	if err != nil {
+		return "", err
+	}
+	return prompts.Render(source, prompts.CurrentVars())
+}
+
+// loadSystemPrompt returns the source of --system if given, otherwise of the named template
+func loadSystemPrompt(template string) (string, error) {
+	if systemPrompt != "" {
+		return systemPrompt, nil
+	}
+	return prompts.Load(template)
+}
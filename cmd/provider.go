@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/misrab/clai/internal/ai"
+)
+
+var (
+	aiProvider          string
+	aiBaseURL           string
+	ollamaURL           string
+	aiHeaders           []string
+	connectTimeout      time.Duration
+	firstTokenTimeout   time.Duration
+	requestTotalTimeout time.Duration
+	aiRetries           int
+	keepAlive           string
+	embeddingModel      string
+	recordCassette      string
+	replayCassette      string
+
+	// cassette records or replays the requests of every provider, created on first use
+	cassette func(http.RoundTripper) http.RoundTripper
+)
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&aiProvider, "provider", ai.ProviderOllama, "AI backend to use (ollama, openai)")
+	flags.StringVar(&aiBaseURL, "base-url", "", "Base URL of the AI backend (default depends on --provider)")
+	flags.StringVar(&ollamaURL, "ollama-url", "", "Ollama server URL (default: $OLLAMA_HOST or http://localhost:11434)")
+	flags.StringArrayVarP(&aiHeaders, "header", "H", nil, "Extra HTTP header for AI requests, e.g. 'Authorization: Bearer TOKEN' (repeatable)")
+	flags.DurationVar(&connectTimeout, "connect-timeout", ai.DefaultTimeouts.Connect, "Timeout for connecting to the AI backend")
+	flags.DurationVar(&firstTokenTimeout, "first-token-timeout", ai.DefaultTimeouts.FirstToken, "Timeout for the first token of a streamed AI answer")
+	flags.DurationVar(&requestTotalTimeout, "timeout", ai.DefaultTimeouts.Total, "Total timeout for a single AI request")
+	flags.IntVar(&aiRetries, "retries", ai.DefaultRetryPolicy.MaxAttempts-1, "Retries for transient AI backend failures (0 disables)")
+	flags.StringVar(&embeddingModel, "embedding-model", ai.DefaultEmbeddingModel, "Model used to compute embeddings")
+	flags.StringVar(&recordCassette, "record", "", "Record AI requests and responses to a cassette file")
+	flags.StringVar(&replayCassette, "replay", "", "Answer AI requests from a cassette file recorded with --record, without a backend")
+	flags.StringVar(&keepAlive, "keep-alive", "", "How long Ollama keeps the model loaded after a request, e.g. 30m or -1 for forever (default: server setting)")
+}
+
+// newProvider returns the AI provider used by the commands. system is the
+// system prompt, empty for requests that don't generate text.
+func newProvider(model string, opts ai.Options, system string) (ai.Provider, error) {
+	headers, err := parseHeaders(aiHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := cassetteTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	return ai.NewProvider(ai.Config{
+		Provider:       aiProvider,
+		BaseURL:        providerBaseURL(),
+		Model:          model,
+		Headers:        headers,
+		EmbeddingModel: embeddingModel,
+		Timeouts: ai.Timeouts{
+			Connect:    connectTimeout,
+			FirstToken: firstTokenTimeout,
+			Total:      requestTotalTimeout,
+		},
+		Options:   opts,
+		System:    system,
+		Retry:     ai.RetryPolicy{MaxAttempts: aiRetries + 1},
+		KeepAlive: keepAlive,
+		Transport: transport,
+	})
+}
+
+// providerBaseURL returns the backend URL from --base-url, or --ollama-url
+// for Ollama. Empty means the provider's default.
+func providerBaseURL() string {
+	if ollamaURL != "" && (aiProvider == "" || aiProvider == ai.ProviderOllama) {
+		return ollamaURL
+	}
+	return aiBaseURL
+}
+
+// cassetteTransport returns the transport wrapper for --record or --replay,
+// or nil. All providers share one cassette so requests stay in order.
+func cassetteTransport() (func(http.RoundTripper) http.RoundTripper, error) {
+	if cassette != nil {
+		return cassette, nil
+	}
+
+	switch {
+	case recordCassette != "" && replayCassette != "":
+		return nil, fmt.Errorf("--record and --replay can't be used together")
+	case recordCassette != "":
+		recorder, err := ai.NewRecorder(recordCassette)
+		if err != nil {
+			return nil, err
+		}
+		cassette = recorder.Wrap
+	case replayCassette != "":
+		replayer, err := ai.NewReplayer(replayCassette)
+		if err != nil {
+			return nil, err
+		}
+		cassette = replayer.Wrap
+	}
+	return cassette, nil
+}
+
+// parseHeaders parses "Name: value" flag values into an http.Header
+func parseHeaders(values []string) (http.Header, error) {
+	headers := http.Header{}
+	for _, value := range values {
+		name, val, ok := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q (expected 'Name: value')", value)
+		}
+		headers.Add(name, strings.TrimSpace(val))
+	}
+	return headers, nil
+}
@@ -4,13 +4,16 @@ import (
 	"embed"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/misrab/clai/internal/ai"
 	"github.com/spf13/cobra"
 )
 
 var (
 	aiModel         string
 	useDummy        bool
+	verbose         bool
 	maxPromptLength int
 
 	// webuiAssets holds the embedded web UI files
@@ -29,8 +32,9 @@ func SetWebuiAssets(assets embed.FS) {
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&aiModel, "model", "codellama:7b", "Ollama model to use")
+	rootCmd.PersistentFlags().StringVar(&aiModel, "model", "", "Model to use for every subcommand, overriding --bash-model and --chat-model")
 	rootCmd.PersistentFlags().BoolVar(&useDummy, "dummy", false, "Use dummy AI (no Ollama required)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show token counts and latency for each AI response")
 	rootCmd.PersistentFlags().IntVar(&maxPromptLength, "max-length", 500, "Maximum prompt length in characters")
 	rootCmd.AddCommand(versionCmd)
 }
@@ -43,6 +47,17 @@ func validatePromptLength(prompt string) error {
 	return nil
 }
 
+// printMetrics prints token counts and latency in dim text when --verbose is set
+func printMetrics(m ai.Metrics) {
+	if !verbose || (m.OutputTokens == 0 && m.TotalDuration == 0) {
+		return
+	}
+
+	fmt.Printf("\033[2m[%s] %d prompt tokens, %d output tokens, %.1f tok/s, first token %s, total %s\033[0m\n",
+		m.Model, m.PromptTokens, m.OutputTokens, m.TokensPerSecond(),
+		m.TimeToFirstToken.Round(time.Millisecond), m.TotalDuration.Round(time.Millisecond))
+}
+
 // Execute wires stdout/stderr and runs the root command.
 func Execute() error {
 	rootCmd.SetOut(os.Stdout)
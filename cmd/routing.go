@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/misrab/clai/internal/ai"
+)
+
+var (
+	bashModel      string
+	chatModel      string
+	fallbackModels []string
+)
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&bashModel, "bash-model", ai.DefaultModel, "Model used by bash to generate commands")
+	flags.StringVar(&chatModel, "chat-model", ai.DefaultChatModel, "Model used by chat and the web UI")
+	flags.StringSliceVar(&fallbackModels, "fallback-models", nil, "Models to try in order when the chosen one is missing or fails (comma-separated)")
+}
+
+// bashModelName returns the model bash uses: --model if set, else --bash-model
+func bashModelName() string {
+	if aiModel != "" {
+		return aiModel
+	}
+	return bashModel
+}
+
+// chatModelName returns the model chat uses: --model if set, else --chat-model
+func chatModelName() string {
+	if aiModel != "" {
+		return aiModel
+	}
+	return chatModel
+}
+
+// modelChain returns primary followed by the fallback models, without repeats
+func modelChain(primary string) []string {
+	chain := []string{primary}
+	for _, model := range fallbackModels {
+		if model == "" {
+			continue
+		}
+		seen := false
+		for _, m := range chain {
+			seen = seen || m == model
+		}
+		if !seen {
+			chain = append(chain, model)
+		}
+	}
+	return chain
+}
+
+// newRoutedProvider returns a provider for primary that falls back on the
+// --fallback-models in order, telling the user when it does
+func newRoutedProvider(primary string, opts ai.Options, system string) (ai.Provider, error) {
+	chain := modelChain(primary)
+	if len(chain) == 1 {
+		return newProvider(primary, opts, system)
+	}
+
+	provider, err := ai.NewFallbackProvider(chain, func(model string) (ai.Provider, error) {
+		return newProvider(model, opts, system)
+	})
+	if err != nil {
+		return nil, err
+	}
+	provider.OnFallback = func(model string, err error, next string) {
+		fmt.Printf("\033[2m%s failed (%v), trying %s\033[0m\n", model, err, next)
+	}
+	return provider, nil
+}
+
+// printAnsweredBy tells the user when a fallback model answered instead of primary
+func printAnsweredBy(m ai.Metrics, primary string) {
+	if m.Model != "" && m.Model != primary {
+		fmt.Printf("\033[2m(answered by %s)\033[0m\n", m.Model)
+	}
+}
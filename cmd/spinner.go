@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chzyer/readline"
+)
+
+// spinnerFrames are drawn in turn while waiting for the model
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// commandView shows a command while it is generated: a spinner until the
+// first token arrives, then the streamed text after prefix. finish erases
+// both so the cleaned-up command can be printed in their place. Outside a
+// terminal it shows nothing.
+type commandView struct {
+	prefix    string
+	enabled   bool
+	streaming bool
+	text      strings.Builder
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newCommandView starts the spinner
+func newCommandView(prefix string) *commandView {
+	v := &commandView{prefix: prefix, enabled: readline.IsTerminal(int(os.Stdout.Fd()))}
+	if v.enabled {
+		v.stop = make(chan struct{})
+		v.done = make(chan struct{})
+		go v.spin()
+	}
+	return v
+}
+
+func (v *commandView) spin() {
+	defer close(v.done)
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		fmt.Printf("\r\033[K\033[2m%s Generating command...\033[0m", spinnerFrames[i%len(spinnerFrames)])
+		select {
+		case <-v.stop:
+			fmt.Print("\r\033[K")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopSpinner stops the spinner and clears its line
+func (v *commandView) stopSpinner() {
+	if v.stop != nil {
+		close(v.stop)
+		<-v.done
+		v.stop = nil
+	}
+}
+
+// write shows the next piece of the command; it is the stream callback of
+// generateCommand
+func (v *commandView) write(chunk string) error {
+	if !v.enabled {
+		return nil
+	}
+	if !v.streaming {
+		v.stopSpinner()
+		v.streaming = true
+		fmt.Print(v.prefix)
+	}
+	v.text.WriteString(chunk)
+	fmt.Printf("\033[2;36m%s\033[0m", chunk)
+	return nil
+}
+
+// finish stops the spinner and erases the streamed command
+func (v *commandView) finish() {
+	if !v.enabled {
+		return
+	}
+	v.stopSpinner()
+	if !v.streaming {
+		return
+	}
+
+	rows := screenRows(v.prefix+v.text.String(), readline.GetScreenWidth())
+	fmt.Print("\r")
+	if rows > 1 {
+		fmt.Printf("\033[%dA", rows-1)
+	}
+	fmt.Print("\033[J")
+	v.streaming = false
+	v.text.Reset()
+}
+
+// screenRows returns how many terminal rows text takes up when wrapped at width
+func screenRows(text string, width int) int {
+	if width <= 0 {
+		width = 80
+	}
+	rows := 0
+	for _, line := range strings.Split(text, "\n") {
+		n := utf8.RuneCountInString(line)
+		if n == 0 {
+			rows++
+			continue
+		}
+		rows += (n + width - 1) / width
+	}
+	return rows
+}
+
+// thinkingView shows the reasoning of a thinking model while it streams:
+// in full and dimmed when expanded, otherwise folded into a single line
+// that reports how long the model thought
+type thinkingView struct {
+	expand  bool
+	started time.Time
+	active  bool
+}
+
+// write shows the next piece of reasoning; it is the ai.WithThinking callback
+func (v *thinkingView) write(chunk string) error {
+	if !v.active {
+		v.active = true
+		v.started = time.Now()
+		if !v.expand {
+			fmt.Print("\033[2m▸ Thinking...\033[0m")
+		}
+	}
+	if v.expand {
+		fmt.Printf("\033[2m%s\033[0m", chunk)
+	}
+	return nil
+}
+
+// finish ends the reasoning once the answer starts
+func (v *thinkingView) finish() {
+	if !v.active {
+		return
+	}
+	v.active = false
+	if v.expand {
+		fmt.Print("\n\n")
+		return
+	}
+	fmt.Printf("\r\033[K\033[2m▸ Thought for %s\033[0m\n", time.Since(v.started).Round(100*time.Millisecond))
+}
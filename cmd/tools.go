@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/safety"
+)
+
+const (
+	// maxToolOutput caps how much of a tool's output is sent back to the model
+	maxToolOutput = 16 << 10
+	// maxGrepMatches caps the number of lines grep returns
+	maxGrepMatches = 200
+	// maxToolRounds stops a model that keeps calling tools without answering
+	maxToolRounds = 10
+)
+
+// localTool is a tool the chat model may call, run on this machine once the
+// user approves it
+type localTool struct {
+	ai.Tool
+	// editArg is the argument shown and changed by "edit" at the approval prompt
+	editArg string
+	run     func(ctx context.Context, call ai.ToolCall) (string, error)
+}
+
+// localTools are the tools offered to the model with --tools
+var localTools = []localTool{
+	{
+		Tool: ai.Tool{
+			Name:        "read_file",
+			Description: "Read the contents of a text file",
+			Parameters:  toolSchema(map[string]string{"path": "Path of the file to read"}, "path"),
+		},
+		editArg: "path",
+		run:     readFileTool,
+	},
+	{
+		Tool: ai.Tool{
+			Name:        "list_dir",
+			Description: "List the entries of a directory; subdirectories end with /",
+			Parameters:  toolSchema(map[string]string{"path": "Directory to list (default: current directory)"}),
+		},
+		editArg: "path",
+		run:     listDirTool,
+	},
+	{
+		Tool: ai.Tool{
+			Name:        "grep",
+			Description: "Search files under a directory for lines matching a regular expression",
+			Parameters: toolSchema(map[string]string{
+				"pattern": "Regular expression (Go syntax) to search for",
+				"path":    "File or directory to search (default: current directory)",
+			}, "pattern"),
+		},
+		editArg: "pattern",
+		run:     grepTool,
+	},
+	{
+		Tool: ai.Tool{
+			Name:        "run_shell",
+			Description: "Run a shell command with sh -c and return its combined output",
+			Parameters:  toolSchema(map[string]string{"command": "The shell command to run"}, "command"),
+		},
+		editArg: "command",
+		run:     runShellTool,
+	},
+}
+
+// toolDefinitions returns the definitions of the local tools sent to the model
+func toolDefinitions() []ai.Tool {
+	tools := make([]ai.Tool, 0, len(localTools))
+	for _, t := range localTools {
+		tools = append(tools, t.Tool)
+	}
+	return tools
+}
+
+// toolSchema builds the JSON schema of an object with string properties
+func toolSchema(properties map[string]string, required ...string) map[string]interface{} {
+	props := make(map[string]interface{}, len(properties))
+	for name, description := range properties {
+		props[name] = map[string]interface{}{"type": "string", "description": description}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+}
+
+// findTool returns the local tool with the given name, or nil
+func findTool(name string) *localTool {
+	for i := range localTools {
+		if localTools[i].Name == name {
+			return &localTools[i]
+		}
+	}
+	return nil
+}
+
+// runToolCalls asks the user to approve each call, runs the approved ones and
+// returns one RoleTool message per call for the model
+func runToolCalls(ctx context.Context, calls []ai.ToolCall) []ai.Message {
+	results := make([]ai.Message, 0, len(calls))
+	for _, call := range calls {
+		result, err := runToolCall(ctx, call)
+		if ctx.Err() != nil {
+			// Every call still gets a result, so the history stays valid to send
+			result = "The user interrupted this tool call."
+		} else if err != nil {
+			fmt.Printf("\033[31m%s failed: %v\033[0m\n", call.Function.Name, err)
+			result = fmt.Sprintf("Error: %v", err)
+		}
+		results = append(results, ai.Message{Role: ai.RoleTool, Content: result, ToolName: call.Function.Name})
+	}
+	return results
+}
+
+// runToolCall approves and runs a single tool call
+func runToolCall(ctx context.Context, call ai.ToolCall) (string, error) {
+	tool := findTool(call.Function.Name)
+	if tool == nil {
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	approved, err := approveToolCall(ctx, tool, &call)
+	if err != nil {
+		return "", err
+	}
+	if !approved {
+		return "The user denied this tool call.", nil
+	}
+
+	output, err := tool.run(ctx, call)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("\033[2m✓ %s returned %d lines\033[0m\n", tool.Name, strings.Count(output, "\n"))
+	return truncateOutput(output), nil
+}
+
+// approveToolCall shows a tool call and asks whether to run it, like
+// promptAndExecute does for commands. Editing changes the tool's main argument,
+// and dangerous shell commands need "yes" typed out. Ctrl+C at the prompt
+// denies the call once the answer is read.
+func approveToolCall(ctx context.Context, tool *localTool, call *ai.ToolCall) (bool, error) {
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		fmt.Printf("\n\033[1;33mTool call:\033[0m %s %s\n", tool.Name, formatCommand(call.StringArg(tool.editArg)))
+		for name, value := range call.Function.Arguments {
+			if name != tool.editArg {
+				fmt.Printf("  %s: %v\n", name, value)
+			}
+		}
+
+		var dangers []safety.Danger
+		if tool.Name == "run_shell" {
+			dangers = checkCommand(call.StringArg("command"))
+		}
+		if len(dangers) > 0 {
+			printDangers(dangers)
+			fmt.Print("Type yes to run, or [n/e] ")
+		} else {
+			fmt.Print("Run? [Y/n/e] ")
+		}
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			return false, err
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+
+		response = strings.ToLower(strings.TrimSpace(response))
+		switch response {
+		case "", "y", "yes":
+			if len(dangers) > 0 && response != "yes" {
+				fmt.Println("This command is dangerous: type yes in full to run it")
+				continue
+			}
+			return true, nil
+		case "n", "no":
+			fmt.Println("Denied")
+			return false, nil
+		case "e", "edit":
+			edited, ok, err := editLine(call.StringArg(tool.editArg))
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				fmt.Println("Denied")
+				return false, nil
+			}
+			if call.Function.Arguments == nil {
+				call.Function.Arguments = map[string]interface{}{}
+			}
+			call.Function.Arguments[tool.editArg] = edited
+			continue
+		default:
+			fmt.Println("Invalid option, denied")
+			return false, nil
+		}
+	}
+}
+
+// readFileTool returns the contents of a file
+func readFileTool(ctx context.Context, call ai.ToolCall) (string, error) {
+	path := call.StringArg("path")
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if isBinary(data) {
+		return "", fmt.Errorf("%s is a binary file", path)
+	}
+	return string(data), nil
+}
+
+// listDirTool lists the entries of a directory
+func listDirTool(ctx context.Context, call ai.ToolCall) (string, error) {
+	path := call.StringArg("path")
+	if path == "" {
+		path = "."
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, entry := range entries {
+		b.WriteString(entry.Name())
+		if entry.IsDir() {
+			b.WriteString("/")
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// grepTool searches files for lines matching a regular expression, skipping
+// hidden directories, node_modules and binary files
+func grepTool(ctx context.Context, call ai.ToolCall) (string, error) {
+	re, err := regexp.Compile(call.StringArg("pattern"))
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	root := call.StringArg("path")
+	if root == "" {
+		root = "."
+	}
+
+	var b strings.Builder
+	matches := 0
+	errLimit := errors.New("match limit reached")
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable entries are skipped
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || isBinary(data) {
+			return nil
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			if !re.MatchString(line) {
+				continue
+			}
+			fmt.Fprintf(&b, "%s:%d: %s\n", path, i+1, line)
+			if matches++; matches >= maxGrepMatches {
+				return errLimit
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errLimit) {
+		fmt.Fprintf(&b, "(stopped after %d matches)\n", maxGrepMatches)
+	} else if err != nil {
+		return "", err
+	}
+
+	if matches == 0 {
+		return "No matches", nil
+	}
+	return b.String(), nil
+}
+
+// runShellTool runs a shell command and returns its combined output
+func runShellTool(ctx context.Context, call ai.ToolCall) (string, error) {
+	command := call.StringArg("command")
+	if command == "" {
+		return "", fmt.Errorf("command is required")
+	}
+
+	output, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// A failing command is still a result the model can act on
+		return fmt.Sprintf("%s\n(%v)", output, exitErr), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// isBinary reports whether data looks like a binary file
+func isBinary(data []byte) bool {
+	if len(data) > 512 {
+		data = data[:512]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// truncateOutput caps a tool's output at maxToolOutput bytes
+func truncateOutput(output string) string {
+	if len(output) <= maxToolOutput {
+		return output
+	}
+	return output[:maxToolOutput] + fmt.Sprintf("\n(output truncated, %d bytes total)", len(output))
+}
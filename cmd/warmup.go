@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/misrab/clai/internal/ai"
+	"github.com/spf13/cobra"
+)
+
+var warmupCmd = &cobra.Command{
+	Use:   "warmup [model]",
+	Short: "Load a model into memory (defaults to the bash and chat models)",
+	Long: `Loads a model into memory so the next request doesn't wait for it.
+Combine with --keep-alive to control how long it stays loaded, e.g.
+
+  clai warmup --keep-alive 1h`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return warmupModel(cmd.Context(), args[0])
+		}
+		if err := warmupModel(cmd.Context(), bashModelName()); err != nil {
+			return err
+		}
+		if chatModelName() == bashModelName() {
+			return nil
+		}
+		return warmupModel(cmd.Context(), chatModelName())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(warmupCmd)
+}
+
+// warmupModel loads a model and reports how long it took
+func warmupModel(ctx context.Context, name string) error {
+	if err := ensureModelInstalled(ctx, name); err != nil {
+		return err
+	}
+
+	provider, err := newProvider(name, ai.Options{}, "")
+	if err != nil {
+		return err
+	}
+	manager, ok := provider.(ai.ModelManager)
+	if !ok {
+		return fmt.Errorf("provider %q does not support loading models", aiProvider)
+	}
+
+	fmt.Printf("Loading %s...\n", name)
+	start := time.Now()
+	if err := manager.LoadModel(ctx, name); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Loaded %s in %s", name, time.Since(start).Round(100*time.Millisecond))
+	if keepAlive != "" {
+		fmt.Printf(" (keep-alive %s)", keepAlive)
+	}
+	fmt.Println()
+	return nil
+}
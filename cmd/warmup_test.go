@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/ai/aitest"
+)
+
+func TestKeepAliveIsSentWithEveryRequest(t *testing.T) {
+	tests := []struct {
+		flag     string
+		expected string // keep_alive as sent, in JSON
+	}{
+		{flag: "10m", expected: `"10m"`},
+		{flag: "-1", expected: `-1`}, // A quoted "-1" is not a valid duration
+		{flag: "0", expected: `0`},
+		{flag: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			srv := useFakeOllama(t)
+			prevKeepAlive := keepAlive
+			keepAlive = tt.flag
+			t.Cleanup(func() { keepAlive = prevKeepAlive })
+
+			var err error
+			captureStdout(t, func() { err = warmupModel(context.Background(), aitest.DefaultModel) })
+			if err != nil {
+				t.Fatalf("warmupModel: %v", err)
+			}
+			if _, _, err := generateCommand(context.Background(), "list files", nil); err != nil {
+				t.Fatalf("generateCommand: %v", err)
+			}
+			captureStdout(t, func() {
+				_, err = chatTurn(context.Background(), nil, nil, ai.Message{Role: ai.RoleUser, Content: "hi"})
+			})
+			if err != nil {
+				t.Fatalf("chatTurn: %v", err)
+			}
+
+			requests := srv.Requests()
+			if len(requests) != 3 {
+				t.Fatalf("got %d requests, want warmup, command and chat", len(requests))
+			}
+			for _, req := range requests {
+				if req.KeepAlive != tt.expected {
+					t.Errorf("%s sent keep_alive %s, want %s", req.Path, req.KeepAlive, tt.expected)
+				}
+			}
+		})
+	}
+}
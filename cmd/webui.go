@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"github.com/misrab/clai/internal/ai"
 	"github.com/misrab/clai/internal/webui"
 	"github.com/spf13/cobra"
 )
@@ -14,11 +15,30 @@ var (
 		Short: "Start the web UI",
 		Long:  "Start a local web server and open the clai web interface in your browser",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return webui.Start(webuiAssets, webuiPort, !webuiNoBrowser)
+			summarize, err := summarizeContext()
+			if err != nil {
+				return err
+			}
+
+			// Requests may override the chat model, defaults and command-line options
+			newWebProvider := func(model string, opts ai.Options) (ai.Provider, error) {
+				if model == "" {
+					model = chatModelName()
+				}
+				return newChatProvider(model, webGenerationOptions(opts))
+			}
+			contextOpts := webui.ContextOptions{MaxTokens: contextTokens(), Summarize: summarize}
+			return webui.Start(webuiAssets, webuiPort, !webuiNoBrowser, chatModelName(), newWebProvider, contextOpts)
 		},
 	}
 )
 
+// webGenerationOptions applies the options of a web UI request over the chat
+// defaults and the command-line options
+func webGenerationOptions(request ai.Options) ai.Options {
+	return generationOptions(chatDefaultOptions).Merge(request)
+}
+
 func init() {
 	webuiCmd.Flags().IntVarP(&webuiPort, "port", "p", 8080, "Port to run web server on")
 	webuiCmd.Flags().BoolVar(&webuiNoBrowser, "no-browser", false, "Don't auto-open browser")
@@ -0,0 +1,423 @@
+// Package aitest provides a fake Ollama server for tests. It speaks
+// /api/generate, /api/chat, /api/tags and the model management endpoints
+// /api/pull, /api/show and /api/delete, including streamed NDJSON, and
+// answers with scripted responses so the real client code can be exercised
+// end to end without a model.
+package aitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/misrab/clai/internal/ai"
+)
+
+// DefaultModel is the model the server reports as installed
+const DefaultModel = "aitest:latest"
+
+// Response scripts one answer of the server
+type Response struct {
+	// Content is the answer's text. Streams send it in Chunks if set,
+	// otherwise split into words.
+	Content string
+	Chunks  []string
+	// ToolCalls are sent with the answer of /api/chat
+	ToolCalls []ai.ToolCall
+
+	// Status, when set to anything but 200, fails the request with Error as the message
+	Status int
+	Error  string
+	// StreamError is sent as an error line after the chunks of a stream
+	StreamError string
+
+	// Delay is waited before answering, ChunkDelay between streamed chunks
+	Delay      time.Duration
+	ChunkDelay time.Duration
+}
+
+// Request is a request received by the server
+type Request struct {
+	Path     string
+	Model    string
+	Prompt   string // /api/generate only
+	System   string // /api/generate only
+	Messages []ai.Message
+	Tools    []string // Names of the tools offered to /api/chat
+	Stream   bool
+	// KeepAlive is keep_alive as sent, in JSON: a quoted duration such as
+	// "10m", a number of seconds such as -1, or empty when left out
+	KeepAlive string
+}
+
+// Server is a fake Ollama server. Responses are used in the order they were
+// enqueued; once they run out, DefaultCommand answers /api/generate and
+// DefaultReply answers /api/chat. /api/pull installs the model it is asked
+// for, /api/show describes and /api/delete removes one of Models.
+type Server struct {
+	*httptest.Server
+
+	// Models are listed by /api/tags
+	Models []ai.Model
+
+	DefaultCommand ai.Command
+	DefaultReply   string
+
+	mu        sync.Mutex
+	responses []Response
+	requests  []Request
+}
+
+// NewServer starts a fake Ollama server that is closed when the test ends
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{
+		Models:         []ai.Model{{Name: DefaultModel, Size: 1 << 30}},
+		DefaultCommand: ai.Command{Command: "echo hello", Explanation: "Prints hello", Risk: ai.RiskLow},
+		DefaultReply:   "Hello from aitest!",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/generate", s.handleGenerate)
+	mux.HandleFunc("/api/chat", s.handleChat)
+	mux.HandleFunc("/api/tags", s.handleTags)
+	mux.HandleFunc("/api/pull", s.handlePull)
+	mux.HandleFunc("/api/show", s.handleShow)
+	mux.HandleFunc("/api/delete", s.handleDelete)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Command returns a response answering GenerateCommand with cmd
+func Command(cmd ai.Command) Response {
+	data, _ := json.Marshal(cmd)
+	return Response{Content: string(data)}
+}
+
+// Config returns a provider configuration that talks to the server without retries
+func (s *Server) Config() ai.Config {
+	return ai.Config{
+		Provider: ai.ProviderOllama,
+		BaseURL:  s.URL,
+		Model:    DefaultModel,
+		Retry:    ai.RetryPolicy{MaxAttempts: 1},
+	}
+}
+
+// Enqueue scripts the next responses
+func (s *Server) Enqueue(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, responses...)
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// next records a request and returns the response to send, or fallback
+// when no scripted response is left
+func (s *Server) next(req Request, fallback func() Response) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+	if len(s.responses) == 0 {
+		return fallback()
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model     string          `json:"model"`
+		Prompt    string          `json:"prompt"`
+		System    string          `json:"system"`
+		Stream    *bool           `json:"stream"`
+		KeepAlive json.RawMessage `json:"keep_alive"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stream := body.Stream == nil || *body.Stream
+	req := Request{Path: r.URL.Path, Model: body.Model, Prompt: body.Prompt, System: body.System, Stream: stream, KeepAlive: string(body.KeepAlive)}
+	resp := s.next(req, func() Response { return Command(s.DefaultCommand) })
+	s.answer(w, r, resp, stream, func(content string, done bool) map[string]interface{} {
+		return map[string]interface{}{"model": body.Model, "response": content, "done": done}
+	})
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model     string          `json:"model"`
+		Messages  []ai.Message    `json:"messages"`
+		Stream    *bool           `json:"stream"`
+		KeepAlive json.RawMessage `json:"keep_alive"`
+		Tools     []struct {
+			Function ai.Tool `json:"function"`
+		} `json:"tools"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	req := Request{
+		Path:      r.URL.Path,
+		Model:     body.Model,
+		Messages:  body.Messages,
+		Stream:    body.Stream == nil || *body.Stream,
+		KeepAlive: string(body.KeepAlive),
+	}
+	for _, tool := range body.Tools {
+		req.Tools = append(req.Tools, tool.Function.Name)
+	}
+	resp := s.next(req, func() Response { return Response{Content: s.DefaultReply} })
+
+	toolCallsSent := false
+	s.answer(w, r, resp, req.Stream, func(content string, done bool) map[string]interface{} {
+		msg := ai.Message{Role: ai.RoleAssistant, Content: content}
+		// Tool calls arrive with the first chunk of a stream, or the whole answer
+		if !toolCallsSent {
+			msg.ToolCalls = resp.ToolCalls
+			toolCallsSent = true
+		}
+		return map[string]interface{}{"model": body.Model, "message": msg, "done": done}
+	})
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	models := s.Models
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"models": models})
+}
+
+func (s *Server) handlePull(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model  string `json:"model"`
+		Stream *bool  `json:"stream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	req := Request{Path: r.URL.Path, Model: body.Model, Stream: body.Stream == nil || *body.Stream}
+	resp := s.next(req, func() Response { return Response{} })
+	if !sleep(r, resp.Delay) {
+		return
+	}
+	if resp.Status != 0 && resp.Status != http.StatusOK {
+		writeError(w, resp.Status, resp.Error)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	const size = 4 << 20
+	progress := []ai.PullProgress{
+		{Status: "pulling manifest"},
+		{Status: "pulling 1234abcd", Digest: "sha256:1234abcd", Total: size},
+		{Status: "pulling 1234abcd", Digest: "sha256:1234abcd", Total: size, Completed: size / 2},
+		{Status: "pulling 1234abcd", Digest: "sha256:1234abcd", Total: size, Completed: size},
+		{Status: "verifying sha256 digest"},
+	}
+	for i, p := range progress {
+		if i > 0 && !sleep(r, resp.ChunkDelay) {
+			return
+		}
+		enc.Encode(p)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if resp.StreamError != "" {
+		enc.Encode(map[string]string{"error": resp.StreamError})
+		return
+	}
+
+	s.mu.Lock()
+	if !ai.HasModel(s.Models, body.Model) {
+		s.Models = append(s.Models, ai.Model{Name: body.Model, Size: size})
+	}
+	s.mu.Unlock()
+	enc.Encode(ai.PullProgress{Status: "success"})
+}
+
+func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.modelRequest(w, r); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"parameters": "temperature 0.7\nstop \"<|end|>\"",
+		"template":   "{{ .Prompt }}",
+		"details": map[string]string{
+			"family":             "aitest",
+			"parameter_size":     "1B",
+			"quantization_level": "Q4_0",
+		},
+		"model_info": map[string]interface{}{
+			"general.architecture":  "aitest",
+			"aitest.context_length": 4096,
+		},
+	})
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	model, ok := s.modelRequest(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	for i, m := range s.Models {
+		if ai.HasModel([]ai.Model{m}, model) {
+			s.Models = append(s.Models[:i:i], s.Models[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+}
+
+// modelRequest records a request naming one model and returns the model. It
+// reports false once it has answered with an error itself: a scripted one, or
+// 404 for a model that isn't installed, like Ollama does.
+func (s *Server) modelRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var body struct {
+		Model string `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+
+	resp := s.next(Request{Path: r.URL.Path, Model: body.Model}, func() Response {
+		if ai.HasModel(s.Models, body.Model) {
+			return Response{}
+		}
+		return Response{Status: http.StatusNotFound, Error: fmt.Sprintf("model '%s' not found", body.Model)}
+	})
+	if !sleep(r, resp.Delay) {
+		return "", false
+	}
+	if resp.Status != 0 && resp.Status != http.StatusOK {
+		writeError(w, resp.Status, resp.Error)
+		return "", false
+	}
+	return body.Model, true
+}
+
+// answer writes resp as a single JSON object or an NDJSON stream. message
+// builds the endpoint-specific object for a piece of content.
+func (s *Server) answer(w http.ResponseWriter, r *http.Request, resp Response, stream bool,
+	message func(content string, done bool) map[string]interface{}) {
+
+	if !sleep(r, resp.Delay) {
+		return
+	}
+
+	if resp.Status != 0 && resp.Status != http.StatusOK {
+		writeError(w, resp.Status, resp.Error)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+
+	if !stream {
+		enc.Encode(withStats(message(resp.Content, true), len(chunks(resp))))
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	parts := chunks(resp)
+	for i, chunk := range parts {
+		if i > 0 && !sleep(r, resp.ChunkDelay) {
+			return
+		}
+		enc.Encode(message(chunk, false))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if resp.StreamError != "" {
+		enc.Encode(map[string]string{"error": resp.StreamError})
+		return
+	}
+	enc.Encode(withStats(message("", true), len(parts)))
+}
+
+// chunks splits the response's content into the pieces to stream
+func chunks(resp Response) []string {
+	if len(resp.Chunks) > 0 {
+		return resp.Chunks
+	}
+	if resp.Content == "" {
+		return []string{""}
+	}
+
+	var parts []string
+	words := strings.SplitAfter(resp.Content, " ")
+	for _, word := range words {
+		if word != "" {
+			parts = append(parts, word)
+		}
+	}
+	return parts
+}
+
+// withStats adds Ollama's final timings and token counts to a message
+func withStats(m map[string]interface{}, tokens int) map[string]interface{} {
+	m["prompt_eval_count"] = 10
+	m["prompt_eval_duration"] = int64(time.Millisecond)
+	m["eval_count"] = tokens
+	m["eval_duration"] = int64(tokens) * int64(time.Millisecond)
+	m["total_duration"] = int64(tokens+1) * int64(time.Millisecond)
+	return m
+}
+
+// sleep waits d, returning false if the client went away first
+func sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(d):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// writeError answers with an Ollama-style JSON error
+func writeError(w http.ResponseWriter, status int, msg string) {
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
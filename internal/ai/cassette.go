@@ -0,0 +1,282 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Cassette holds recorded requests to an AI backend and their responses, in
+// the order they happened. Streamed responses are kept line by line so a
+// replay delivers the same chunks.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and what came back
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded request. Headers are left out so credentials
+// never end up in a cassette.
+type RecordedRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// RecordedResponse is a recorded response, or the error returned instead of one
+type RecordedResponse struct {
+	Status  int      `json:"status,omitempty"`
+	Chunks  []string `json:"chunks,omitempty"`
+	Error   string   `json:"error,omitempty"`
+	Timeout bool     `json:"timeout,omitempty"` // Error was a timeout
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Recorder records every request made through the transports it wraps,
+// saving the cassette to its file after each interaction
+type Recorder struct {
+	path string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder records into the cassette file at path, replacing its contents
+func NewRecorder(path string) (*Recorder, error) {
+	r := &Recorder{path: path}
+	if err := r.cassette.Save(path); err != nil {
+		return nil, fmt.Errorf("create cassette: %w", err)
+	}
+	return r, nil
+}
+
+// Wrap returns a transport that records the requests sent through next.
+// Use it as Config.Transport.
+func (r *Recorder) Wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		recorded, err := recordRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			var netErr net.Error
+			timeout := errors.As(err, &netErr) && netErr.Timeout()
+			r.add(Interaction{Request: recorded, Response: RecordedResponse{Error: err.Error(), Timeout: timeout}})
+			return nil, err
+		}
+
+		// The body is recorded as the client reads it, so streams still stream
+		resp.Body = &recordingBody{
+			ReadCloser: resp.Body,
+			done: func(body []byte) {
+				r.add(Interaction{Request: recorded, Response: RecordedResponse{Status: resp.StatusCode, Chunks: splitLines(body)}})
+			},
+		}
+		return resp, nil
+	})
+}
+
+// add appends an interaction and saves the cassette
+func (r *Recorder) add(interaction Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if err := r.cassette.Save(r.path); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save cassette: %v\n", err)
+	}
+}
+
+// Replayer answers requests from a cassette, in order, without any network access
+type Replayer struct {
+	path string
+
+	mu       sync.Mutex
+	cassette *Cassette
+	next     int
+}
+
+// NewReplayer replays the cassette file at path
+func NewReplayer(path string) (*Replayer, error) {
+	c, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{path: path, cassette: c}, nil
+}
+
+// Wrap returns a transport that answers from the cassette; next is never used.
+// Use it as Config.Transport.
+func (r *Replayer) Wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(r.roundTrip)
+}
+
+func (r *Replayer) roundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.cassette.Interactions) {
+		return nil, fmt.Errorf("cassette %s has no recorded response for %s %s", r.path, req.Method, req.URL.Path)
+	}
+	interaction := r.cassette.Interactions[r.next]
+	r.next++
+
+	if recorded := interaction.Request; recorded.Method != req.Method || recorded.Path != req.URL.Path {
+		return nil, fmt.Errorf("cassette %s expected %s %s as request %d, got %s %s",
+			r.path, recorded.Method, recorded.Path, r.next, req.Method, req.URL.Path)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	if interaction.Response.Error != "" {
+		return nil, &replayedError{msg: interaction.Response.Error, timeout: interaction.Response.Timeout}
+	}
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+		StatusCode: interaction.Response.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       &chunkReader{chunks: interaction.Response.Chunks},
+		Request:    req,
+	}, nil
+}
+
+// replayedError is a recorded transport failure, such as a refused
+// connection. It is a net.Error so timeouts are classified as before.
+type replayedError struct {
+	msg     string
+	timeout bool
+}
+
+func (e *replayedError) Error() string   { return e.msg }
+func (e *replayedError) Timeout() bool   { return e.timeout }
+func (e *replayedError) Temporary() bool { return false }
+
+// recordRequest captures a request, leaving its body readable
+func recordRequest(req *http.Request) (RecordedRequest, error) {
+	recorded := RecordedRequest{Method: req.Method, Path: req.URL.Path}
+	if req.Body == nil {
+		return recorded, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return recorded, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(body) > 0 {
+		recorded.Body = json.RawMessage(body)
+		if !json.Valid(body) {
+			recorded.Body, _ = json.Marshal(string(body))
+		}
+	}
+	return recorded, nil
+}
+
+// recordingBody keeps a copy of what is read from a response body and hands
+// it to done once the body is fully read or closed
+type recordingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func(body []byte)
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *recordingBody) finish() {
+	b.once.Do(func() { b.done(b.buf.Bytes()) })
+}
+
+// chunkReader replays recorded chunks, returning at most one chunk per Read
+type chunkReader struct {
+	chunks  []string
+	current string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for r.current == "" {
+		if len(r.chunks) == 0 {
+			return 0, io.EOF
+		}
+		r.current, r.chunks = r.chunks[0], r.chunks[1:]
+	}
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
+
+func (r *chunkReader) Close() error {
+	return nil
+}
+
+// splitLines splits a body into lines, keeping the line endings, so NDJSON
+// and SSE streams are recorded chunk by chunk
+func splitLines(body []byte) []string {
+	var lines []string
+	for _, line := range strings.SplitAfter(string(body), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
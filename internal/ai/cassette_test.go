@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		flusher := w.(http.Flusher)
+		for _, chunk := range []string{"Hel", "lo", "!"} {
+			fmt.Fprintf(w, "{\"message\":{\"role\":\"assistant\",\"content\":%q},\"done\":false}\n", chunk)
+			flusher.Flush()
+		}
+		fmt.Fprint(w, "{\"message\":{\"role\":\"assistant\",\"content\":\"\"},\"done\":true,\"eval_count\":3}\n")
+	}))
+
+	path := filepath.Join(t.TempDir(), "chat.json")
+	messages := []Message{{Role: RoleUser, Content: "hi"}}
+
+	stream := func(transport func(http.RoundTripper) http.RoundTripper) ([]string, *Reply, error) {
+		client := NewOllamaClient(Config{BaseURL: srv.URL, Model: "m", Transport: transport, Retry: RetryPolicy{MaxAttempts: 1}})
+		var chunks []string
+		reply, err := client.ChatStream(context.Background(), messages, func(chunk string) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+		return chunks, reply, err
+	}
+
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	recorded, _, err := stream(recorder.Wrap)
+	if err != nil {
+		t.Fatalf("ChatStream while recording: %v", err)
+	}
+	srv.Close()
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	if len(cassette.Interactions) != 1 || len(cassette.Interactions[0].Response.Chunks) != 4 {
+		t.Fatalf("cassette = %+v, want one interaction with 4 chunks", cassette)
+	}
+
+	// The server is gone, so the answer can only come from the cassette
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	replayed, reply, err := stream(replayer.Wrap)
+	if err != nil {
+		t.Fatalf("ChatStream while replaying: %v", err)
+	}
+	if strings.Join(replayed, "|") != strings.Join(recorded, "|") || reply.Content != "Hello!" {
+		t.Fatalf("replayed %q (content %q), recorded %q", replayed, reply.Content, recorded)
+	}
+	if reply.Metrics.OutputTokens != 3 {
+		t.Errorf("replayed completion tokens = %d, want 3", reply.Metrics.OutputTokens)
+	}
+
+	if _, _, err := stream(replayer.Wrap); err == nil {
+		t.Fatal("ChatStream past the end of the cassette succeeded")
+	}
+}
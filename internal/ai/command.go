@@ -0,0 +1,198 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Risk levels reported for a generated command
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// Command is a generated shell command together with the model's description of it
+type Command struct {
+	Command     string   `json:"command"`
+	Explanation string   `json:"explanation"`
+	Files       []string `json:"files"`
+	Risk        string   `json:"risk"`
+
+	Metrics Metrics `json:"-"`
+}
+
+// CommandStreamer is implemented by providers that can stream command
+// generation. The callback receives the command text as it arrives, before
+// it is cleaned up; the returned Command is final.
+type CommandStreamer interface {
+	GenerateCommandStream(ctx context.Context, prompt string, callback func(string) error) (*Command, error)
+}
+
+// commandSchema is the JSON schema the model's answer must follow
+var commandSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"command":     map[string]interface{}{"type": "string"},
+		"explanation": map[string]interface{}{"type": "string"},
+		"files": map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "string"},
+		},
+		"risk": map[string]interface{}{
+			"type": "string",
+			"enum": []string{RiskLow, RiskMedium, RiskHigh},
+		},
+	},
+	"required": []string{"command", "explanation", "files", "risk"},
+}
+
+// defaultCommandSystem is the system prompt for command generation when none is configured
+const defaultCommandSystem = `You are a bash command generator. Convert the request into a single bash command.
+Use standard Unix/Linux/macOS commands.`
+
+// commandSystem returns the configured system prompt for command generation, or the default
+func commandSystem(system string) string {
+	if system == "" {
+		return defaultCommandSystem
+	}
+	return system
+}
+
+// commandPrompt builds the prompt used to turn a request into a bash command.
+// The answer format is fixed here so parseCommand can read it whatever the system prompt says.
+func commandPrompt(prompt string) string {
+	return fmt.Sprintf(`Respond with a JSON object with these fields:
+- "command": the bash command only, on a single line (use && or ; for multiple operations)
+- "explanation": one short sentence describing what the command does
+- "files": paths or globs the command reads, writes or deletes (empty if none)
+- "risk": "low" for read-only commands, "medium" for commands that modify files or state, "high" for destructive or irreversible commands
+
+Request: %s`, prompt)
+}
+
+// withSystem prepends the system prompt to a conversation that doesn't start with one
+func withSystem(system string, messages []Message) []Message {
+	if system == "" || (len(messages) > 0 && messages[0].Role == RoleSystem) {
+		return messages
+	}
+	return append([]Message{{Role: RoleSystem, Content: system}}, messages...)
+}
+
+// parseCommand decodes the model's JSON answer. Models that ignore the schema
+// and answer with plain text still yield a usable command.
+func parseCommand(raw string) (*Command, error) {
+	_, raw = SplitThinking(raw)
+
+	var cmd Command
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &cmd); err != nil || cmd.Command == "" {
+		cmd = Command{Command: cleanCommand(raw)}
+	}
+
+	cmd.Command = cleanCommand(cmd.Command)
+	if cmd.Command == "" {
+		return nil, fmt.Errorf("model returned no command")
+	}
+
+	// A missing or unknown risk level is treated as medium
+	switch cmd.Risk {
+	case RiskLow, RiskMedium, RiskHigh:
+	default:
+		cmd.Risk = RiskMedium
+	}
+	cmd.Explanation = strings.TrimSpace(cmd.Explanation)
+
+	return &cmd, nil
+}
+
+// cleanCommand strips common AI artifacts from a generated command
+func cleanCommand(raw string) string {
+	cmd := strings.TrimSpace(raw)
+	cmd = strings.Trim(cmd, "`")
+	cmd = strings.TrimPrefix(cmd, "bash\n")
+	cmd = strings.TrimPrefix(cmd, "sh\n")
+	return strings.TrimSpace(cmd)
+}
+
+// commandStream collects a streamed answer and passes the growing command
+// preview on to callback. Reasoning of thinking models is left out.
+type commandStream struct {
+	split    thinkSplitter
+	raw      strings.Builder
+	sent     string
+	callback func(string) error
+}
+
+// write adds a chunk of the answer, calling callback with any new command text
+func (s *commandStream) write(chunk string) error {
+	_, content := s.split.write(chunk)
+	return s.add(content)
+}
+
+// close adds any text held back by write
+func (s *commandStream) close() error {
+	_, content := s.split.flush()
+	return s.add(content)
+}
+
+func (s *commandStream) add(content string) error {
+	s.raw.WriteString(content)
+	preview := commandPreview(s.raw.String())
+	if len(preview) <= len(s.sent) || !strings.HasPrefix(preview, s.sent) {
+		return nil
+	}
+	delta := preview[len(s.sent):]
+	s.sent = preview
+	return s.callback(delta)
+}
+
+// commandPreview extracts the command from a partial answer: the "command"
+// field of a JSON answer as far as it has arrived, or the text of a plain
+// answer. Incomplete escape sequences are left out until they are complete.
+func commandPreview(raw string) string {
+	const space = " \t\r\n"
+
+	text := strings.TrimLeft(raw, space)
+	if !strings.HasPrefix(text, "{") {
+		return text
+	}
+
+	i := strings.Index(text, `"command"`)
+	if i < 0 {
+		return ""
+	}
+	rest := strings.TrimLeft(text[i+len(`"command"`):], space)
+	if !strings.HasPrefix(rest, ":") {
+		return ""
+	}
+	rest = strings.TrimLeft(rest[1:], space)
+	if !strings.HasPrefix(rest, `"`) {
+		return ""
+	}
+	rest = rest[1:]
+
+	var b strings.Builder
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '"':
+			return b.String()
+		case '\\':
+			end := 2
+			if len(rest) >= 2 && rest[1] == 'u' {
+				end = 6
+			}
+			var decoded string
+			if len(rest) < end || json.Unmarshal([]byte(`"`+rest[:end]+`"`), &decoded) != nil {
+				return b.String()
+			}
+			b.WriteString(decoded)
+			rest = rest[end:]
+		default:
+			b.WriteByte(rest[0])
+			rest = rest[1:]
+		}
+	}
+	return b.String()
+}
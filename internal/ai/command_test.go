@@ -0,0 +1,74 @@
+package ai
+
+import "testing"
+
+func TestParseCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		raw      string
+		command  string
+		risk     string
+		hasFiles bool
+	}{
+		{
+			name:     "structured",
+			raw:      `{"command":"rm -rf build","explanation":"Delete the build directory","files":["build"],"risk":"high"}`,
+			command:  "rm -rf build",
+			risk:     RiskHigh,
+			hasFiles: true,
+		},
+		{
+			name:    "plain text fallback",
+			raw:     "```bash\nls -la\n```",
+			command: "ls -la",
+			risk:    RiskMedium,
+		},
+		{
+			name:    "unknown risk",
+			raw:     `{"command":"df -h","explanation":"Show disk usage","files":[],"risk":"none"}`,
+			command: "df -h",
+			risk:    RiskMedium,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseCommand(tt.raw)
+			if err != nil {
+				t.Fatalf("parseCommand(%q) error: %v", tt.raw, err)
+			}
+			if got.Command != tt.command || got.Risk != tt.risk || (len(got.Files) > 0) != tt.hasFiles {
+				t.Fatalf("parseCommand(%q) = %+v, want command %q risk %q", tt.raw, got, tt.command, tt.risk)
+			}
+		})
+	}
+}
+
+func TestCommandPreview(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		raw      string
+		expected string
+	}{
+		{raw: "", expected: ""},
+		{raw: `{"comm`, expected: ""},
+		{raw: `{"command": "ls -l`, expected: "ls -l"},
+		{raw: `{"command":"grep \"a`, expected: `grep "a`},
+		{raw: `{"command":"echo \`, expected: "echo "},
+		{raw: `{"command":"echo é`, expected: "echo é"},
+		{raw: `{"command":"df -h","explanation":"Show`, expected: "df -h"},
+		{raw: "\n```bash\nls", expected: "```bash\nls"},
+	}
+
+	for _, tt := range tests {
+		if got := commandPreview(tt.raw); got != tt.expected {
+			t.Errorf("commandPreview(%q) = %q, want %q", tt.raw, got, tt.expected)
+		}
+	}
+}
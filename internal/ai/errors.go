@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors describing why a request to an AI backend failed.
+// Errors returned by providers wrap one of these; test with errors.Is.
+var (
+	ErrUnavailable     = errors.New("AI backend unavailable")
+	ErrModelNotFound   = errors.New("model not found")
+	ErrTimeout         = errors.New("AI request timed out")
+	ErrContextOverflow = errors.New("prompt exceeds the model's context window")
+	ErrServer          = errors.New("AI backend error")
+)
+
+// errEmptyResponse is returned when a backend answers without any content
+var errEmptyResponse = &Error{Kind: ErrServer, Message: "AI backend returned an empty response"}
+
+// Error is a failed request to an AI backend
+type Error struct {
+	Kind    error  // One of the sentinel errors above
+	Status  int    // HTTP status code, when the backend answered
+	Message string // Human-readable description
+	Err     error  // Underlying cause, if any
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap exposes both the sentinel kind and the underlying cause to errors.Is
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// transportError classifies a request that got no response. Cancellation is
+// returned as-is; unavailable describes an unreachable server.
+func transportError(ctx context.Context, err error, unavailable string) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &Error{
+			Kind:    ErrTimeout,
+			Message: "request timed out (raise --first-token-timeout or --timeout for slow models)",
+			Err:     err,
+		}
+	}
+
+	return &Error{Kind: ErrUnavailable, Message: unavailable, Err: err}
+}
+
+// readError classifies a failure to read a response body, such as a stream
+// cut off by a timeout or a dropped connection. Invalid JSON is a server error.
+func readError(ctx context.Context, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return &Error{Kind: ErrServer, Message: fmt.Sprintf("invalid response from the AI backend: %v", err), Err: err}
+	}
+	return transportError(ctx, err, fmt.Sprintf("connection to the AI backend lost: %v", err))
+}
+
+// responseKind picks the sentinel error for a failed response from its
+// status code and the backend's error message
+func responseKind(status int, msg string) error {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "context length") || strings.Contains(lower, "context size") ||
+		strings.Contains(lower, "context window"):
+		return ErrContextOverflow
+	case strings.Contains(lower, "model") && (strings.Contains(lower, "not found") || status == http.StatusNotFound):
+		// Other 404s, such as a wrong base URL, are server errors
+		return ErrModelNotFound
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case status == http.StatusBadGateway || status == http.StatusServiceUnavailable:
+		return ErrUnavailable
+	default:
+		return ErrServer
+	}
+}
+
+// modelNotFoundError reports a missing model with a hint on how to get it
+func modelNotFoundError(model string, status int) error {
+	return &Error{
+		Kind:    ErrModelNotFound,
+		Status:  status,
+		Message: fmt.Sprintf("model '%s' not found. Download it with:\n  clai models pull %s", model, model),
+	}
+}
+
+// contextOverflowError reports a conversation that no longer fits the model
+func contextOverflowError(msg string, status int) error {
+	return &Error{
+		Kind:    ErrContextOverflow,
+		Status:  status,
+		Message: fmt.Sprintf("prompt exceeds the model's context window (raise --num-ctx or shorten the conversation): %s", msg),
+	}
+}
@@ -0,0 +1,118 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseKind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status   int
+		msg      string
+		expected error
+	}{
+		{status: http.StatusNotFound, msg: "model 'llama3' not found, try pulling it first", expected: ErrModelNotFound},
+		{status: http.StatusNotFound, msg: "The model `gpt-5` does not exist", expected: ErrModelNotFound},
+		{status: 0, msg: "model \"llama3\" not found", expected: ErrModelNotFound},
+		{status: http.StatusNotFound, msg: "404 page not found", expected: ErrServer},
+		{status: http.StatusNotFound, msg: "Not Found", expected: ErrServer},
+		{status: http.StatusBadRequest, msg: "input length exceeds the context length", expected: ErrContextOverflow},
+		{status: http.StatusGatewayTimeout, msg: "upstream timed out", expected: ErrTimeout},
+		{status: http.StatusServiceUnavailable, msg: "loading", expected: ErrUnavailable},
+		{status: http.StatusInternalServerError, msg: "out of memory", expected: ErrServer},
+	}
+
+	for _, tt := range tests {
+		if got := responseKind(tt.status, tt.msg); got != tt.expected {
+			t.Errorf("responseKind(%d, %q) = %v, want %v", tt.status, tt.msg, got, tt.expected)
+		}
+	}
+}
+
+func TestOllamaWrongPathIsServerError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	_, err := NewOllamaClient(Config{BaseURL: srv.URL}).Chat(context.Background(), []Message{{Role: RoleUser, Content: "hi"}})
+	var aiErr *Error
+	if !errors.Is(err, ErrServer) || !errors.As(err, &aiErr) || aiErr.Status != http.StatusNotFound {
+		t.Errorf("Chat error = %v, want ErrServer with status 404", err)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	// stall sends one chunk of a stream, then nothing until the client gives up
+	stall := func(chunk string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, chunk)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}
+	ollamaChunk := `{"message":{"role":"assistant","content":"Hel"},"done":false}`
+	openAIChunk := `data: {"choices":[{"delta":{"content":"Hel"}}]}`
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		chat     func(ctx context.Context, cfg Config) error
+		expected error
+	}{
+		{name: "ollama timeout", handler: stall(ollamaChunk), chat: ollamaChatStream, expected: ErrTimeout},
+		{name: "openai timeout", handler: stall(openAIChunk), chat: openAIChatStream, expected: ErrTimeout},
+		{
+			name: "ollama invalid json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, ollamaChunk+"\n{oops")
+			},
+			chat:     ollamaChatStream,
+			expected: ErrServer,
+		},
+		{
+			name: "openai invalid json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, openAIChunk+"\ndata: {oops")
+			},
+			chat:     openAIChatStream,
+			expected: ErrServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			t.Cleanup(srv.Close)
+
+			cfg := Config{BaseURL: srv.URL, Timeouts: Timeouts{Total: 200 * time.Millisecond}}
+			if err := tt.chat(context.Background(), cfg); !errors.Is(err, tt.expected) {
+				t.Errorf("ChatStream error = %v, want %v", err, tt.expected)
+			}
+		})
+	}
+
+	// Cancellation is still reported as such
+	srv := httptest.NewServer(stall(ollamaChunk))
+	t.Cleanup(srv.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := ollamaChatStream(ctx, Config{BaseURL: srv.URL}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ChatStream error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func ollamaChatStream(ctx context.Context, cfg Config) error {
+	_, err := NewOllamaClient(cfg).ChatStream(ctx, []Message{{Role: RoleUser, Content: "hi"}}, func(string) error { return nil })
+	return err
+}
+
+func openAIChatStream(ctx context.Context, cfg Config) error {
+	_, err := NewOpenAIClient(cfg).ChatStream(ctx, []Message{{Role: RoleUser, Content: "hi"}}, func(string) error { return nil })
+	return err
+}
@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+)
+
+// FallbackProvider asks a list of models in order, moving on to the next
+// one when a model fails, e.g. because it isn't installed. A stream only
+// falls back while nothing of it has been passed on yet.
+type FallbackProvider struct {
+	models    []string
+	providers []Provider
+
+	// OnFallback, if set, is called before the next model is tried
+	OnFallback func(model string, err error, next string)
+}
+
+var (
+	_ Provider        = (*FallbackProvider)(nil)
+	_ ToolCaller      = (*FallbackProvider)(nil)
+	_ CommandStreamer = (*FallbackProvider)(nil)
+)
+
+// NewFallbackProvider builds a provider for each model, in order of preference
+func NewFallbackProvider(models []string, newProvider func(model string) (Provider, error)) (*FallbackProvider, error) {
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no models to fall back on")
+	}
+
+	f := &FallbackProvider{models: models}
+	for _, model := range models {
+		provider, err := newProvider(model)
+		if err != nil {
+			return nil, err
+		}
+		f.providers = append(f.providers, provider)
+	}
+	return f, nil
+}
+
+// Primary returns the provider of the preferred model
+func (f *FallbackProvider) Primary() Provider {
+	return f.providers[0]
+}
+
+// GenerateCommand converts a natural language prompt into a bash command
+func (f *FallbackProvider) GenerateCommand(ctx context.Context, prompt string) (*Command, error) {
+	var cmd *Command
+	err := f.try(ctx, func(p Provider) (bool, error) {
+		var err error
+		cmd, err = p.GenerateCommand(ctx, prompt)
+		return true, err
+	})
+	return cmd, err
+}
+
+// GenerateCommandStream generates a command, streaming it from models that support it
+func (f *FallbackProvider) GenerateCommandStream(ctx context.Context, prompt string, callback func(string) error) (*Command, error) {
+	var cmd *Command
+	err := f.try(ctx, func(p Provider) (bool, error) {
+		streamer, ok := p.(CommandStreamer)
+		if !ok {
+			var err error
+			cmd, err = p.GenerateCommand(ctx, prompt)
+			return true, err
+		}
+
+		var streamed bool
+		var err error
+		cmd, err = streamer.GenerateCommandStream(ctx, prompt, func(chunk string) error {
+			streamed = true
+			return callback(chunk)
+		})
+		return !streamed, err
+	})
+	return cmd, err
+}
+
+// Chat sends the conversation history and returns the assistant's reply
+func (f *FallbackProvider) Chat(ctx context.Context, messages []Message) (*Reply, error) {
+	var reply *Reply
+	err := f.try(ctx, func(p Provider) (bool, error) {
+		var err error
+		reply, err = p.Chat(ctx, messages)
+		return true, err
+	})
+	return reply, err
+}
+
+// ChatStream streams the assistant's reply to the conversation history
+func (f *FallbackProvider) ChatStream(ctx context.Context, messages []Message, callback func(string) error) (*Reply, error) {
+	var reply *Reply
+	err := f.try(ctx, func(p Provider) (bool, error) {
+		var streamed bool
+		var err error
+		reply, err = p.ChatStream(ctx, messages, func(chunk string) error {
+			streamed = true
+			return callback(chunk)
+		})
+		return !streamed, err
+	})
+	return reply, err
+}
+
+// ChatStreamTools streams the assistant's reply, letting the model call tools.
+// Models whose provider can't call tools are skipped.
+func (f *FallbackProvider) ChatStreamTools(ctx context.Context, messages []Message, tools []Tool, callback func(string) error) (*Reply, error) {
+	var reply *Reply
+	err := f.try(ctx, func(p Provider) (bool, error) {
+		toolCaller, ok := p.(ToolCaller)
+		if !ok {
+			return true, fmt.Errorf("provider does not support tool calling")
+		}
+
+		var streamed bool
+		var err error
+		reply, err = toolCaller.ChatStreamTools(ctx, messages, tools, func(chunk string) error {
+			streamed = true
+			return callback(chunk)
+		})
+		return !streamed, err
+	})
+	return reply, err
+}
+
+// ListModels returns the models available to the preferred model's provider
+func (f *FallbackProvider) ListModels(ctx context.Context) ([]Model, error) {
+	return f.Primary().ListModels(ctx)
+}
+
+// try calls fn with each provider in turn until one succeeds. fn reports
+// whether a failure may still fall back to the next model. Once ctx is done
+// nothing else is tried.
+func (f *FallbackProvider) try(ctx context.Context, fn func(p Provider) (canFallBack bool, err error)) error {
+	var err error
+	for i, provider := range f.providers {
+		var canFallBack bool
+		if canFallBack, err = fn(provider); err == nil {
+			return nil
+		}
+		if !canFallBack || ctx.Err() != nil || i == len(f.providers)-1 {
+			break
+		}
+		if f.OnFallback != nil {
+			f.OnFallback(f.models[i], err, f.models[i+1])
+		}
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package ai
+
+import "time"
+
+// Metrics describes the cost and speed of a single generation
+type Metrics struct {
+	Model            string        `json:"model,omitempty"` // Model that answered
+	PromptTokens     int           `json:"prompt_tokens"`
+	OutputTokens     int           `json:"output_tokens"`
+	LoadDuration     time.Duration `json:"load_duration"`
+	PromptDuration   time.Duration `json:"prompt_duration"`
+	EvalDuration     time.Duration `json:"eval_duration"`
+	TotalDuration    time.Duration `json:"total_duration"`
+	TimeToFirstToken time.Duration `json:"time_to_first_token"`
+}
+
+// TokensPerSecond returns the output generation speed. Backends that don't
+// report the generation time are measured from the first token to the end.
+func (m Metrics) TokensPerSecond() float64 {
+	d := m.EvalDuration
+	if d <= 0 {
+		d = m.TotalDuration - m.TimeToFirstToken
+	}
+	if d <= 0 || m.OutputTokens == 0 {
+		return 0
+	}
+	return float64(m.OutputTokens) / d.Seconds()
+}
+
+// Reply is the assistant's answer to a conversation
+type Reply struct {
+	Content   string
+	Thinking  string     // Reasoning of thinking models, without <think> tags
+	ToolCalls []ToolCall // Tools the model asked to call, see ToolCaller
+	Metrics   Metrics
+}
+
+// stopwatch measures the client-side latency of a request
+type stopwatch struct {
+	start      time.Time
+	firstToken time.Duration
+}
+
+func startStopwatch() *stopwatch {
+	return &stopwatch{start: time.Now()}
+}
+
+// token records the arrival of an output chunk
+func (s *stopwatch) token() {
+	if s.firstToken == 0 {
+		s.firstToken = time.Since(s.start)
+	}
+}
+
+// fill sets the latencies the backend didn't report itself
+func (s *stopwatch) fill(m *Metrics) {
+	if m.TimeToFirstToken == 0 {
+		m.TimeToFirstToken = s.firstToken
+	}
+	if m.TotalDuration == 0 {
+		m.TotalDuration = time.Since(s.start)
+	}
+}
@@ -2,217 +2,512 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
-const defaultOllamaURL = "http://localhost:11434"
+const (
+	defaultOllamaURL  = "http://localhost:11434"
+	defaultOllamaPort = "11434"
+)
 
-// Client represents an Ollama API client
-type Client struct {
-	URL   string
-	Model string
+// OllamaClient is a Provider backed by the Ollama API
+type OllamaClient struct {
+	URL            string
+	Model          string
+	EmbeddingModel string
+	Headers        http.Header
+	Options        Options
+	Retry          RetryPolicy
+	System         string
+
+	// KeepAlive is sent as keep_alive with every request; empty uses the server default
+	KeepAlive string
+
+	client     *http.Client
+	firstToken time.Duration // Timeout for the first chunk of a stream
 }
 
+var (
+	_ Provider        = (*OllamaClient)(nil)
+	_ ToolCaller      = (*OllamaClient)(nil)
+	_ CommandStreamer = (*OllamaClient)(nil)
+)
+
 type ollamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model     string      `json:"model"`
+	Prompt    string      `json:"prompt"`
+	System    string      `json:"system,omitempty"`
+	Stream    bool        `json:"stream"`
+	Options   *Options    `json:"options,omitempty"`
+	Format    interface{} `json:"format,omitempty"`
+	KeepAlive interface{} `json:"keep_alive,omitempty"`
 }
 
 type ollamaResponse struct {
 	Response string `json:"response"`
+	Thinking string `json:"thinking,omitempty"`
 	Done     bool   `json:"done"`
 	Error    string `json:"error,omitempty"`
+	ollamaStats
 }
 
-// NewClient creates a new Ollama client
-func NewClient(model string) *Client {
-	if model == "" {
-		model = "codellama:7b"
-	}
-	return &Client{
-		URL:   defaultOllamaURL,
-		Model: model,
-	}
+type ollamaChatRequest struct {
+	Model     string       `json:"model"`
+	Messages  []Message    `json:"messages"`
+	Stream    bool         `json:"stream"`
+	Options   *Options     `json:"options,omitempty"`
+	KeepAlive interface{}  `json:"keep_alive,omitempty"`
+	Tools     []ollamaTool `json:"tools,omitempty"`
 }
 
-// GenerateCommand converts a natural language prompt into a bash command
-func (c *Client) GenerateCommand(prompt string) (string, error) {
-	systemPrompt := fmt.Sprintf(`You are a bash command generator. Convert the request into a single bash command.
-
-CRITICAL RULES:
-- Output ONLY the bash command itself
-- NO explanations, descriptions, or commentary
-- NO markdown formatting or backticks
-- NO "Here's the command:" or similar phrases
-- Single line preferred (use && or ; for multiple operations)
-- Use standard Unix/Linux/macOS commands
+type ollamaTool struct {
+	Type     string `json:"type"`
+	Function Tool   `json:"function"`
+}
 
-Request: %s
+type ollamaChatResponse struct {
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error,omitempty"`
+	ollamaStats
+}
 
-Bash command:`, prompt)
+// ollamaStats are the timings and token counts sent with the final response.
+// Durations are in nanoseconds.
+type ollamaStats struct {
+	TotalDuration      int64 `json:"total_duration"`
+	LoadDuration       int64 `json:"load_duration"`
+	PromptEvalCount    int   `json:"prompt_eval_count"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration"`
+	EvalCount          int   `json:"eval_count"`
+	EvalDuration       int64 `json:"eval_duration"`
+}
 
-	reqBody := ollamaRequest{
-		Model:  c.Model,
-		Prompt: systemPrompt,
-		Stream: false,
+// metrics converts Ollama's stats. Without a stream, the first token is
+// assumed to arrive once the model is loaded and the prompt evaluated.
+func (s ollamaStats) metrics() Metrics {
+	return Metrics{
+		PromptTokens:     s.PromptEvalCount,
+		OutputTokens:     s.EvalCount,
+		LoadDuration:     time.Duration(s.LoadDuration),
+		PromptDuration:   time.Duration(s.PromptEvalDuration),
+		EvalDuration:     time.Duration(s.EvalDuration),
+		TotalDuration:    time.Duration(s.TotalDuration),
+		TimeToFirstToken: time.Duration(s.LoadDuration + s.PromptEvalDuration),
 	}
+}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", err
+type ollamaTagsResponse struct {
+	Models []Model `json:"models"`
+}
+
+// NewOllamaClient creates a new Ollama client. When cfg.BaseURL is empty the
+// server address is taken from OLLAMA_HOST, falling back to localhost:11434.
+func NewOllamaClient(cfg Config) *OllamaClient {
+	model := cfg.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	embeddingModel := cfg.EmbeddingModel
+	if embeddingModel == "" {
+		embeddingModel = DefaultEmbeddingModel
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Post(c.URL+"/api/generate", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("ollama not running? Install: https://ollama.ai")
+	url := cfg.BaseURL
+	if url == "" {
+		url = ollamaHostURL(os.Getenv("OLLAMA_HOST"))
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	return &OllamaClient{
+		URL:            strings.TrimRight(url, "/"),
+		Model:          model,
+		EmbeddingModel: embeddingModel,
+		Headers:        cfg.Headers,
+		Options:        cfg.Options,
+		Retry:          cfg.Retry,
+		System:         cfg.System,
+		KeepAlive:      cfg.KeepAlive,
+		client:         newHTTPClient(cfg.Timeouts, cfg.Transport),
+		firstToken:     cfg.Timeouts.withDefaults().FirstToken,
 	}
+}
 
-	var ollamaResp ollamaResponse
-	if err := json.Unmarshal(body, &ollamaResp); err != nil {
-		return "", err
+// ollamaHostURL turns an OLLAMA_HOST value such as "0.0.0.0", "gpu-box:11434"
+// or "https://ollama.example.com" into a base URL, following Ollama's own rules
+func ollamaHostURL(host string) string {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return defaultOllamaURL
 	}
 
-	// Check for errors in response
-	if ollamaResp.Error != "" {
-		if strings.Contains(ollamaResp.Error, "not found") {
-			return "", fmt.Errorf("model '%s' not found. Download it with:\n  ollama pull %s", c.Model, c.Model)
-		}
-		return "", fmt.Errorf("ollama error: %s", ollamaResp.Error)
+	scheme := "http"
+	if i := strings.Index(host, "://"); i >= 0 {
+		scheme, host = host[:i], host[i+3:]
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
+	path := ""
+	if i := strings.Index(host, "/"); i >= 0 {
+		host, path = host[:i], host[i:]
 	}
 
-	if ollamaResp.Response == "" {
-		return "", fmt.Errorf("ollama returned empty response")
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		port := defaultOllamaPort
+		if scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
 	}
 
-	cmd := strings.TrimSpace(ollamaResp.Response)
-	// Clean up common AI artifacts
-	cmd = strings.Trim(cmd, "`")
-	cmd = strings.TrimPrefix(cmd, "bash\n")
-	cmd = strings.TrimPrefix(cmd, "sh\n")
+	return scheme + "://" + host + path
+}
 
+// GenerateCommand converts a natural language prompt into a bash command,
+// using Ollama's structured outputs to get a typed answer
+func (c *OllamaClient) GenerateCommand(ctx context.Context, prompt string) (*Command, error) {
+	response, err := c.generate(ctx, commandPrompt(prompt), commandSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err := parseCommand(response.Response)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Metrics = response.metrics()
+	cmd.Metrics.Model = c.Model
 	return cmd, nil
 }
 
-// Chat has a conversation with the AI (non-streaming)
-func (c *Client) Chat(prompt string) (string, error) {
-	reqBody := ollamaRequest{
-		Model:  c.Model,
-		Prompt: prompt,
-		Stream: false,
+// GenerateCommandStream generates a command like GenerateCommand, streaming
+// the command text to the callback as it arrives
+func (c *OllamaClient) GenerateCommandStream(ctx context.Context, prompt string, callback func(string) error) (_ *Command, err error) {
+	ctx, firstToken := startFirstTokenTimer(ctx, c.firstToken)
+	defer func() { err = firstToken.stop(err) }()
+
+	timer := startStopwatch()
+	resp, err := c.send(ctx, http.MethodPost, "/api/generate", c.generateRequest(commandPrompt(prompt), commandSchema, true))
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
+
+	stream := &commandStream{callback: callback}
+	var metrics Metrics
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var ollamaResp ollamaResponse
+		if err := decoder.Decode(&ollamaResp); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, readError(ctx, err)
+		}
+		// Ollama sends each line as soon as the model produced it
+		firstToken.received()
+
+		if ollamaResp.Error != "" {
+			return nil, c.responseError(0, ollamaResp.Error)
+		}
+
+		if ollamaResp.Response != "" {
+			timer.token()
+			if err := stream.write(ollamaResp.Response); err != nil {
+				return nil, err
+			}
+		}
 
-	jsonData, err := json.Marshal(reqBody)
+		if ollamaResp.Done {
+			metrics = ollamaResp.metrics()
+			// The measured first token includes network and queueing time
+			metrics.TimeToFirstToken = 0
+			break
+		}
+	}
+
+	if err := stream.close(); err != nil {
+		return nil, err
+	}
+	if stream.raw.Len() == 0 {
+		return nil, errEmptyResponse
+	}
+	cmd, err := parseCommand(stream.raw.String())
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	cmd.Metrics = metrics
+	cmd.Metrics.Model = c.Model
+	timer.fill(&cmd.Metrics)
+	return cmd, nil
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Post(c.URL+"/api/generate", "application/json", bytes.NewBuffer(jsonData))
+// Chat sends the conversation history to /api/chat and returns the assistant's reply (non-streaming)
+func (c *OllamaClient) Chat(ctx context.Context, messages []Message) (*Reply, error) {
+	resp, err := c.postChat(ctx, messages, nil, false)
 	if err != nil {
-		return "", fmt.Errorf("ollama not running? Install: https://ollama.ai")
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, readError(ctx, err)
 	}
 
-	var ollamaResp ollamaResponse
+	var ollamaResp ollamaChatResponse
 	if err := json.Unmarshal(body, &ollamaResp); err != nil {
-		return "", err
+		return nil, readError(ctx, err)
 	}
 
 	// Check for errors in response
 	if ollamaResp.Error != "" {
-		if strings.Contains(ollamaResp.Error, "not found") {
-			return "", fmt.Errorf("model '%s' not found. Download it with:\n  ollama pull %s", c.Model, c.Model)
-		}
-		return "", fmt.Errorf("ollama error: %s", ollamaResp.Error)
+		return nil, c.responseError(resp.StatusCode, ollamaResp.Error)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
+	thinking, content := SplitThinking(ollamaResp.Message.Content)
+	if content == "" {
+		return nil, errEmptyResponse
 	}
 
-	if ollamaResp.Response == "" {
-		return "", fmt.Errorf("ollama returned empty response")
+	reply := &Reply{
+		Content:  strings.TrimSpace(content),
+		Thinking: strings.TrimSpace(ollamaResp.Message.Thinking + "\n" + thinking),
+		Metrics:  ollamaResp.metrics(),
 	}
+	reply.Metrics.Model = c.Model
+	return reply, nil
+}
 
-	return strings.TrimSpace(ollamaResp.Response), nil
+// ChatStream streams the assistant's reply to the conversation history, calling the callback for each chunk
+func (c *OllamaClient) ChatStream(ctx context.Context, messages []Message, callback func(string) error) (*Reply, error) {
+	return c.chatStream(ctx, messages, nil, callback)
 }
 
-// ChatStream streams the conversation with the AI, calling the callback for each chunk
-func (c *Client) ChatStream(prompt string, callback func(string) error) error {
-	reqBody := ollamaRequest{
-		Model:  c.Model,
-		Prompt: prompt,
-		Stream: true,
-	}
+// ChatStreamTools streams the assistant's reply like ChatStream, letting the model call tools
+func (c *OllamaClient) ChatStreamTools(ctx context.Context, messages []Message, tools []Tool, callback func(string) error) (*Reply, error) {
+	return c.chatStream(ctx, messages, tools, callback)
+}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return err
-	}
+// chatStream streams a reply from /api/chat, collecting any tool calls
+func (c *OllamaClient) chatStream(ctx context.Context, messages []Message, tools []Tool, callback func(string) error) (_ *Reply, err error) {
+	ctx, firstToken := startFirstTokenTimer(ctx, c.firstToken)
+	defer func() { err = firstToken.stop(err) }()
 
-	client := &http.Client{Timeout: 120 * time.Second}
-	resp, err := client.Post(c.URL+"/api/generate", "application/json", bytes.NewBuffer(jsonData))
+	timer := startStopwatch()
+	resp, err := c.postChat(ctx, messages, tools, true)
 	if err != nil {
-		return fmt.Errorf("ollama not running? Install: https://ollama.ai")
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
-	}
-
+	reply := &Reply{}
+	stream := newReplyStream(ctx, callback)
 	decoder := json.NewDecoder(resp.Body)
 	for {
-		var ollamaResp ollamaResponse
+		var ollamaResp ollamaChatResponse
 		if err := decoder.Decode(&ollamaResp); err != nil {
 			if err == io.EOF {
 				break
 			}
-			return err
+			return nil, readError(ctx, err)
 		}
+		firstToken.received()
 
 		// Check for errors in response
 		if ollamaResp.Error != "" {
-			if strings.Contains(ollamaResp.Error, "not found") {
-				return fmt.Errorf("model '%s' not found. Download it with:\n  ollama pull %s", c.Model, c.Model)
-			}
-			return fmt.Errorf("ollama error: %s", ollamaResp.Error)
+			return nil, c.responseError(0, ollamaResp.Error)
 		}
 
-		// Call callback with the chunk
-		if ollamaResp.Response != "" {
-			if err := callback(ollamaResp.Response); err != nil {
-				return err
+		if ollamaResp.Message.Thinking != "" {
+			timer.token()
+			if err := stream.think(ollamaResp.Message.Thinking); err != nil {
+				return nil, err
+			}
+		}
+		if ollamaResp.Message.Content != "" {
+			timer.token()
+			if err := stream.write(ollamaResp.Message.Content); err != nil {
+				return nil, err
 			}
 		}
+		reply.ToolCalls = append(reply.ToolCalls, ollamaResp.Message.ToolCalls...)
 
 		if ollamaResp.Done {
+			reply.Metrics = ollamaResp.metrics()
+			// The measured first token includes network and queueing time
+			reply.Metrics.TimeToFirstToken = 0
 			break
 		}
 	}
 
-	return nil
+	if err := stream.close(); err != nil {
+		return nil, err
+	}
+	reply.Content = stream.content.String()
+	reply.Thinking = stream.thinking.String()
+	reply.Metrics.Model = c.Model
+	timer.fill(&reply.Metrics)
+	return reply, nil
+}
+
+// ListModels returns the models installed in Ollama
+func (c *OllamaClient) ListModels(ctx context.Context) ([]Model, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tags ollamaTagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, err
+	}
+	return tags.Models, nil
+}
+
+// generate sends a single non-streaming prompt to /api/generate. A non-nil
+// format constrains the answer to that JSON schema.
+func (c *OllamaClient) generate(ctx context.Context, prompt string, format interface{}) (*ollamaResponse, error) {
+	resp, err := c.send(ctx, http.MethodPost, "/api/generate", c.generateRequest(prompt, format, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ollamaResp ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
+		return nil, readError(ctx, err)
+	}
+
+	// Check for errors in response
+	if ollamaResp.Error != "" {
+		return nil, c.responseError(resp.StatusCode, ollamaResp.Error)
+	}
+
+	if ollamaResp.Response == "" {
+		return nil, errEmptyResponse
+	}
+
+	return &ollamaResp, nil
+}
+
+// generateRequest builds a request to /api/generate with the command system prompt
+func (c *OllamaClient) generateRequest(prompt string, format interface{}, stream bool) ollamaRequest {
+	return ollamaRequest{
+		Model:     c.Model,
+		Prompt:    prompt,
+		System:    commandSystem(c.System),
+		Stream:    stream,
+		Options:   c.options(),
+		Format:    format,
+		KeepAlive: c.keepAlive(),
+	}
+}
+
+// postChat sends the conversation history to /api/chat, offering the given tools
+func (c *OllamaClient) postChat(ctx context.Context, messages []Message, tools []Tool, stream bool) (*http.Response, error) {
+	reqBody := ollamaChatRequest{
+		Model:     c.Model,
+		Messages:  withSystem(c.System, withoutThinking(messages)),
+		Stream:    stream,
+		Options:   c.options(),
+		KeepAlive: c.keepAlive(),
+	}
+	for _, tool := range tools {
+		reqBody.Tools = append(reqBody.Tools, ollamaTool{Type: "function", Function: tool})
+	}
+	return c.send(ctx, http.MethodPost, "/api/chat", reqBody)
+}
+
+// options returns the generation options to send, or nil when none are set
+func (c *OllamaClient) options() *Options {
+	if c.Options.isZero() {
+		return nil
+	}
+	return &c.Options
+}
+
+// keepAlive returns the keep_alive value to send, or nil for the server
+// default. Ollama reads plain numbers as seconds, so "-1" is sent as a number.
+func (c *OllamaClient) keepAlive() interface{} {
+	if c.KeepAlive == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(c.KeepAlive); err == nil {
+		return n
+	}
+	return c.KeepAlive
+}
+
+// send issues a request to an Ollama endpoint, retrying transient failures.
+// Any answer other than 200 OK is converted into an *Error.
+func (c *OllamaClient) send(ctx context.Context, method, path string, reqBody interface{}) (*http.Response, error) {
+	return c.sendWith(ctx, c.client, method, path, reqBody)
+}
+
+// sendWith is send through a specific HTTP client
+func (c *OllamaClient) sendWith(ctx context.Context, client *http.Client, method, path string, reqBody interface{}) (*http.Response, error) {
+	var jsonData []byte
+	if reqBody != nil {
+		var err error
+		if jsonData, err = json.Marshal(reqBody); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := doWithRetry(ctx, client, c.Retry, func() (*http.Request, error) {
+		var body io.Reader
+		if jsonData != nil {
+			body = bytes.NewReader(jsonData)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.URL+path, body)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		setHeaders(req, c.Headers)
+		return req, nil
+	})
+	if err != nil {
+		return nil, transportError(ctx, err, fmt.Sprintf("ollama not running at %s? Install: https://ollama.ai", c.URL))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+
+		msg := strings.TrimSpace(string(body))
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			msg = errResp.Error
+		}
+		return nil, c.responseError(resp.StatusCode, msg)
+	}
+
+	return resp, nil
+}
+
+// responseError converts an error returned by Ollama into an *Error.
+// status is 0 for errors reported in the middle of a stream.
+func (c *OllamaClient) responseError(status int, msg string) error {
+	switch kind := responseKind(status, msg); kind {
+	case ErrModelNotFound:
+		return modelNotFoundError(c.Model, status)
+	case ErrContextOverflow:
+		return contextOverflowError(msg, status)
+	default:
+		return &Error{Kind: kind, Status: status, Message: fmt.Sprintf("ollama error: %s", msg)}
+	}
 }
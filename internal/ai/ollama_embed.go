@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var _ Embedder = (*OllamaClient)(nil)
+
+type ollamaEmbedRequest struct {
+	Model     string      `json:"model"`
+	Input     []string    `json:"input"`
+	KeepAlive interface{} `json:"keep_alive,omitempty"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// Embed returns an embedding for each text from /api/embed, using EmbeddingModel
+func (c *OllamaClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	reqBody := ollamaEmbedRequest{
+		Model:     c.EmbeddingModel,
+		Input:     texts,
+		KeepAlive: c.keepAlive(),
+	}
+
+	resp, err := c.send(ctx, http.MethodPost, "/api/embed", reqBody)
+	if errors.Is(err, ErrModelNotFound) {
+		return nil, modelNotFoundError(c.EmbeddingModel, http.StatusNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var embedResp ollamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, err
+	}
+
+	if embedResp.Error != "" {
+		return nil, c.responseError(resp.StatusCode, embedResp.Error)
+	}
+
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, &Error{
+			Kind:    ErrServer,
+			Message: fmt.Sprintf("expected %d embeddings, got %d", len(texts), len(embedResp.Embeddings)),
+		}
+	}
+	return embedResp.Embeddings, nil
+}
@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var _ ModelManager = (*OllamaClient)(nil)
+
+type ollamaModelRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream,omitempty"`
+}
+
+type ollamaPullResponse struct {
+	PullProgress
+	Error string `json:"error,omitempty"`
+}
+
+type ollamaShowResponse struct {
+	Parameters string `json:"parameters"`
+	Template   string `json:"template"`
+	Details    struct {
+		Family            string `json:"family"`
+		ParameterSize     string `json:"parameter_size"`
+		QuantizationLevel string `json:"quantization_level"`
+	} `json:"details"`
+	ModelInfo map[string]interface{} `json:"model_info"`
+}
+
+// PullModel downloads a model through /api/pull, reporting progress as it streams
+func (c *OllamaClient) PullModel(ctx context.Context, name string, progress func(PullProgress)) error {
+	// Downloads of several gigabytes outlast the Total timeout, so only ctx bounds them
+	client := *c.client
+	client.Timeout = 0
+
+	resp, err := c.sendWith(ctx, &client, http.MethodPost, "/api/pull", ollamaModelRequest{Model: name, Stream: true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var pullResp ollamaPullResponse
+		if err := decoder.Decode(&pullResp); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return readError(ctx, err)
+		}
+
+		if pullResp.Error != "" {
+			return &Error{Kind: ErrServer, Message: fmt.Sprintf("pull %s: %s", name, pullResp.Error)}
+		}
+
+		if progress != nil {
+			progress(pullResp.PullProgress)
+		}
+
+		if pullResp.Status == "success" {
+			return nil
+		}
+	}
+}
+
+// ShowModel returns details about an installed model from /api/show
+func (c *OllamaClient) ShowModel(ctx context.Context, name string) (*ModelInfo, error) {
+	resp, err := c.send(ctx, http.MethodPost, "/api/show", ollamaModelRequest{Model: name})
+	if errors.Is(err, ErrModelNotFound) {
+		return nil, modelNotFoundError(name, http.StatusNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var showResp ollamaShowResponse
+	if err := json.NewDecoder(resp.Body).Decode(&showResp); err != nil {
+		return nil, err
+	}
+
+	return &ModelInfo{
+		Name:              name,
+		Family:            showResp.Details.Family,
+		ParameterSize:     showResp.Details.ParameterSize,
+		QuantizationLevel: showResp.Details.QuantizationLevel,
+		ContextLength:     contextLength(showResp.ModelInfo),
+		Parameters:        strings.TrimSpace(showResp.Parameters),
+		Template:          strings.TrimSpace(showResp.Template),
+	}, nil
+}
+
+// DeleteModel removes an installed model through /api/delete
+func (c *OllamaClient) DeleteModel(ctx context.Context, name string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/api/delete", ollamaModelRequest{Model: name})
+	if errors.Is(err, ErrModelNotFound) {
+		return &Error{Kind: ErrModelNotFound, Status: http.StatusNotFound, Message: fmt.Sprintf("model '%s' not found", name)}
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// LoadModel loads a model into memory by sending /api/generate an empty prompt,
+// keeping it loaded for KeepAlive
+func (c *OllamaClient) LoadModel(ctx context.Context, name string) error {
+	resp, err := c.send(ctx, http.MethodPost, "/api/generate", ollamaRequest{Model: name, KeepAlive: c.keepAlive()})
+	if errors.Is(err, ErrModelNotFound) {
+		return modelNotFoundError(name, http.StatusNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var loadResp ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&loadResp); err != nil {
+		return err
+	}
+	if loadResp.Error != "" {
+		return &Error{Kind: ErrServer, Message: fmt.Sprintf("load %s: %s", name, loadResp.Error)}
+	}
+	return nil
+}
+
+// contextLength extracts "<architecture>.context_length" from /api/show model_info
+func contextLength(info map[string]interface{}) int {
+	for key, value := range info {
+		if !strings.HasSuffix(key, ".context_length") {
+			continue
+		}
+		if n, ok := value.(float64); ok {
+			return int(n)
+		}
+	}
+	return 0
+}
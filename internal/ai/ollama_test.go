@@ -0,0 +1,213 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOllamaHostURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{host: "", expected: "http://localhost:11434"},
+		{host: "0.0.0.0", expected: "http://0.0.0.0:11434"},
+		{host: "gpu-box:8000", expected: "http://gpu-box:8000"},
+		{host: "https://ollama.example.com", expected: "https://ollama.example.com:443"},
+		{host: "http://proxy.internal/ollama", expected: "http://proxy.internal:11434/ollama"},
+		{host: "[::1]", expected: "http://[::1]:11434"},
+	}
+
+	for _, tt := range tests {
+		if got := ollamaHostURL(tt.host); got != tt.expected {
+			t.Errorf("ollamaHostURL(%q) = %q, want %q", tt.host, got, tt.expected)
+		}
+	}
+}
+
+func TestOllamaEmbed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req ollamaEmbedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Model != "all-minilm" {
+			t.Errorf("model = %q, want all-minilm", req.Model)
+		}
+
+		resp := ollamaEmbedResponse{}
+		for i := range req.Input {
+			resp.Embeddings = append(resp.Embeddings, []float32{float32(i), 0.5})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewOllamaClient(Config{BaseURL: srv.URL, EmbeddingModel: "all-minilm"})
+	vectors, err := client.Embed(context.Background(), []string{"list files", "disk usage"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if got := fmt.Sprint(vectors); got != "[[0 0.5] [1 0.5]]" {
+		t.Errorf("Embed = %s, want [[0 0.5] [1 0.5]]", got)
+	}
+
+	if NewOllamaClient(Config{}).EmbeddingModel != DefaultEmbeddingModel {
+		t.Errorf("EmbeddingModel does not default to %s", DefaultEmbeddingModel)
+	}
+}
+
+func TestOllamaChatStreamTools(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if len(req.Tools) != 1 || req.Tools[0].Type != "function" || req.Tools[0].Function.Name != "read_file" {
+			t.Errorf("tools = %+v, want read_file function", req.Tools)
+		}
+
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"read_file","arguments":{"path":"go.mod"}}}]},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"eval_count":7}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewOllamaClient(Config{BaseURL: srv.URL})
+	tools := []Tool{{Name: "read_file", Description: "Read a file", Parameters: map[string]interface{}{"type": "object"}}}
+	reply, err := client.ChatStreamTools(context.Background(), []Message{{Role: RoleUser, Content: "show go.mod"}}, tools,
+		func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("ChatStreamTools: %v", err)
+	}
+
+	if len(reply.ToolCalls) != 1 {
+		t.Fatalf("got %d tool calls, want 1", len(reply.ToolCalls))
+	}
+	if call := reply.ToolCalls[0]; call.Function.Name != "read_file" || call.StringArg("path") != "go.mod" {
+		t.Errorf("tool call = %+v, want read_file(path=go.mod)", call)
+	}
+	if reply.Metrics.OutputTokens != 7 {
+		t.Errorf("OutputTokens = %d, want 7", reply.Metrics.OutputTokens)
+	}
+}
+
+func TestOllamaGenerateCommandStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream || req.Format == nil {
+			t.Errorf("request = %+v (%v), want a streamed request with a format", req, err)
+		}
+		for _, chunk := range []string{`{"command": "`, "`ls", ` -la`, "`\", \"explanation\": \"List\",", ` "files": [], "risk": "low"}`} {
+			fmt.Fprintf(w, "{\"response\":%q,\"done\":false}\n", chunk)
+		}
+		fmt.Fprint(w, "{\"response\":\"\",\"done\":true,\"eval_count\":5}\n")
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewOllamaClient(Config{BaseURL: srv.URL, Model: "m"})
+	var streamed []string
+	cmd, err := client.GenerateCommandStream(context.Background(), "list files", func(chunk string) error {
+		streamed = append(streamed, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GenerateCommandStream: %v", err)
+	}
+	if got := strings.Join(streamed, "|"); got != "`ls| -la|`" {
+		t.Errorf("streamed %q, want the raw command chunk by chunk", streamed)
+	}
+	if cmd.Command != "ls -la" || cmd.Risk != RiskLow || cmd.Metrics.OutputTokens != 5 {
+		t.Errorf("command = %+v, want low-risk %q with 5 output tokens", cmd, "ls -la")
+	}
+}
+
+func TestFallbackProvider(t *testing.T) {
+	var models []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req.Model)
+		if req.Model == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"model 'missing' not found"}`)
+			return
+		}
+		fmt.Fprint(w, "{\"message\":{\"role\":\"assistant\",\"content\":\"Hi\"},\"done\":false}\n{\"done\":true}\n")
+	}))
+	t.Cleanup(srv.Close)
+
+	var fellBack string
+	provider, err := NewFallbackProvider([]string{"missing", "backup"}, func(model string) (Provider, error) {
+		return NewOllamaClient(Config{BaseURL: srv.URL, Model: model, Retry: RetryPolicy{MaxAttempts: 1}}), nil
+	})
+	if err != nil {
+		t.Fatalf("NewFallbackProvider: %v", err)
+	}
+	provider.OnFallback = func(model string, err error, next string) {
+		if !errors.Is(err, ErrModelNotFound) {
+			t.Errorf("fallback error = %v, want ErrModelNotFound", err)
+		}
+		fellBack = model + " -> " + next
+	}
+
+	reply, err := provider.ChatStream(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}, func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if reply.Content != "Hi" || reply.Metrics.Model != "backup" || fellBack != "missing -> backup" {
+		t.Errorf("reply = %+v after %q, want an answer from backup", reply, fellBack)
+	}
+	if strings.Join(models, ",") != "missing,backup" {
+		t.Errorf("requested models %v, want missing then backup", models)
+	}
+}
+
+func TestOllamaPullOutlastsTotalTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, `{"status":"pulling abc","total":3,"completed":%d}`+"\n", i)
+			flusher.Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+		fmt.Fprintln(w, `{"status":"success"}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewOllamaClient(Config{BaseURL: srv.URL, Timeouts: Timeouts{Total: 50 * time.Millisecond}})
+	var statuses []string
+	err := client.PullModel(context.Background(), "big", func(p PullProgress) {
+		statuses = append(statuses, fmt.Sprintf("%s %d/%d", p.Status, p.Completed, p.Total))
+	})
+	if err != nil {
+		t.Fatalf("PullModel: %v", err)
+	}
+	want := "[pulling abc 1/3 pulling abc 2/3 pulling abc 3/3 success 0/0]"
+	if got := fmt.Sprint(statuses); got != want {
+		t.Errorf("progress = %s, want %s", got, want)
+	}
+
+	// The caller's context still bounds the download
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.PullModel(ctx, "big", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("PullModel with an expired context = %v, want context.DeadlineExceeded", err)
+	}
+}
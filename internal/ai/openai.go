@@ -0,0 +1,505 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultOpenAIURL = "http://localhost:8080/v1"
+
+// OpenAIClient is a Provider for servers speaking the OpenAI chat completions
+// protocol, such as llama.cpp's llama-server, vLLM, LM Studio and LocalAI
+type OpenAIClient struct {
+	BaseURL string
+	Model   string
+	APIKey  string
+	Headers http.Header
+	Options Options
+	Retry   RetryPolicy
+	System  string
+
+	client     *http.Client
+	firstToken time.Duration // Timeout for the first chunk of a stream
+}
+
+var (
+	_ Provider        = (*OpenAIClient)(nil)
+	_ CommandStreamer = (*OpenAIClient)(nil)
+)
+
+type openAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Stream      bool            `json:"stream"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	Seed        *int            `json:"seed,omitempty"`
+	MaxTokens   *int            `json:"max_tokens,omitempty"`
+	Stop        []string        `json:"stop,omitempty"`
+
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+}
+
+// openAIMessage is a request message. Content is a string, or a list of
+// parts when the message carries images.
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    interface{}      `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAIToolCall is a tool call of an assistant message. The arguments are
+// sent as a JSON string.
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string      `json:"name"`
+	Schema interface{} `json:"schema"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message      openAIReplyMessage `json:"message"`
+		Delta        openAIReplyMessage `json:"delta"`
+		FinishReason *string            `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+	Error *openAIError `json:"error,omitempty"`
+}
+
+// openAIReplyMessage is a message or delta of a response. Servers for
+// reasoning models such as DeepSeek's and vLLM send the reasoning apart.
+type openAIReplyMessage struct {
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIError struct {
+	Message string `json:"message"`
+}
+
+type openAIModelsResponse struct {
+	Data []struct {
+		ID      string `json:"id"`
+		Created int64  `json:"created"`
+	} `json:"data"`
+}
+
+// NewOpenAIClient creates a new client for an OpenAI-compatible server.
+// The API key is read from OPENAI_API_KEY; most local servers ignore it.
+func NewOpenAIClient(cfg Config) *OpenAIClient {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultOpenAIURL
+	}
+	model := cfg.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	return &OpenAIClient{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Model:      model,
+		APIKey:     os.Getenv("OPENAI_API_KEY"),
+		Headers:    cfg.Headers,
+		Options:    cfg.Options,
+		Retry:      cfg.Retry,
+		System:     cfg.System,
+		client:     newHTTPClient(cfg.Timeouts, cfg.Transport),
+		firstToken: cfg.Timeouts.withDefaults().FirstToken,
+	}
+}
+
+// GenerateCommand converts a natural language prompt into a bash command,
+// requesting a JSON answer that follows the command schema
+func (c *OpenAIClient) GenerateCommand(ctx context.Context, prompt string) (*Command, error) {
+	messages, format := c.commandRequest(prompt)
+	reply, err := c.complete(ctx, messages, format)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err := parseCommand(reply.Content)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Metrics = reply.Metrics
+	return cmd, nil
+}
+
+// GenerateCommandStream generates a command like GenerateCommand, streaming
+// the command text to the callback as it arrives
+func (c *OpenAIClient) GenerateCommandStream(ctx context.Context, prompt string, callback func(string) error) (*Command, error) {
+	messages, format := c.commandRequest(prompt)
+	stream := &commandStream{callback: callback}
+	reply, err := c.stream(ctx, messages, format, stream.write)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.close(); err != nil {
+		return nil, err
+	}
+	if stream.raw.Len() == 0 {
+		return nil, errEmptyResponse
+	}
+
+	cmd, err := parseCommand(stream.raw.String())
+	if err != nil {
+		return nil, err
+	}
+	cmd.Metrics = reply.Metrics
+	return cmd, nil
+}
+
+// commandRequest returns the messages and response format for command generation
+func (c *OpenAIClient) commandRequest(prompt string) ([]Message, *openAIResponseFormat) {
+	format := &openAIResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &openAIJSONSchema{
+			Name:   "command",
+			Schema: commandSchema,
+		},
+	}
+	messages := []Message{
+		{Role: RoleSystem, Content: commandSystem(c.System)},
+		{Role: RoleUser, Content: commandPrompt(prompt)},
+	}
+	return messages, format
+}
+
+// Chat sends the conversation history and returns the assistant's reply (non-streaming)
+func (c *OpenAIClient) Chat(ctx context.Context, messages []Message) (*Reply, error) {
+	reply, err := c.complete(ctx, messages, nil)
+	if err != nil {
+		return nil, err
+	}
+	thinking, content := SplitThinking(reply.Content)
+	if content == "" {
+		return nil, errEmptyResponse
+	}
+	reply.Content = strings.TrimSpace(content)
+	reply.Thinking = strings.TrimSpace(reply.Thinking + "\n" + thinking)
+	return reply, nil
+}
+
+// ChatStream streams the assistant's reply to the conversation history, calling the callback for each chunk
+func (c *OpenAIClient) ChatStream(ctx context.Context, messages []Message, callback func(string) error) (*Reply, error) {
+	return c.stream(ctx, messages, nil, callback)
+}
+
+// stream streams a completion, calling the callback for each chunk. A
+// non-nil format constrains the answer.
+func (c *OpenAIClient) stream(ctx context.Context, messages []Message, format *openAIResponseFormat, callback func(string) error) (_ *Reply, err error) {
+	ctx, firstToken := startFirstTokenTimer(ctx, c.firstToken)
+	defer func() { err = firstToken.stop(err) }()
+
+	timer := startStopwatch()
+	resp, err := c.post(ctx, messages, true, format)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	reply := &Reply{}
+	stream := newReplyStream(ctx, callback)
+
+	// The stream is a series of SSE "data:" lines terminated by "data: [DONE]"
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, readError(ctx, err)
+		}
+		if chunk.Error != nil {
+			return nil, c.responseError(0, chunk.Error.Message)
+		}
+		// With include_usage the last chunk carries token counts and no choices
+		if chunk.Usage != nil {
+			reply.Metrics.PromptTokens = chunk.Usage.PromptTokens
+			reply.Metrics.OutputTokens = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta
+		// The first chunk, holding just the role, may be sent before the model starts
+		if delta.ReasoningContent != "" || delta.Content != "" {
+			firstToken.received()
+		}
+		if delta.ReasoningContent != "" {
+			timer.token()
+			if err := stream.think(delta.ReasoningContent); err != nil {
+				return nil, err
+			}
+		}
+		if delta.Content != "" {
+			timer.token()
+			if err := stream.write(delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, readError(ctx, err)
+	}
+
+	if err := stream.close(); err != nil {
+		return nil, err
+	}
+	reply.Content = stream.content.String()
+	reply.Thinking = stream.thinking.String()
+	reply.Metrics.Model = c.Model
+	timer.fill(&reply.Metrics)
+	return reply, nil
+}
+
+// ListModels returns the models served by the backend
+func (c *OpenAIClient) ListModels(ctx context.Context) ([]Model, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var list openAIModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	models := make([]Model, 0, len(list.Data))
+	for _, m := range list.Data {
+		model := Model{Name: m.ID}
+		if m.Created > 0 {
+			model.ModifiedAt = time.Unix(m.Created, 0)
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// complete sends a single non-streaming chat completion request. A non-nil
+// format constrains the answer.
+func (c *OpenAIClient) complete(ctx context.Context, messages []Message, format *openAIResponseFormat) (*Reply, error) {
+	timer := startStopwatch()
+	resp, err := c.post(ctx, messages, false, format)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var openAIResp openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
+		return nil, readError(ctx, err)
+	}
+
+	if openAIResp.Error != nil {
+		return nil, c.responseError(resp.StatusCode, openAIResp.Error.Message)
+	}
+
+	if len(openAIResp.Choices) == 0 || openAIResp.Choices[0].Message.Content == "" {
+		return nil, errEmptyResponse
+	}
+
+	message := openAIResp.Choices[0].Message
+	reply := &Reply{Content: message.Content, Thinking: message.ReasoningContent}
+	if openAIResp.Usage != nil {
+		reply.Metrics.PromptTokens = openAIResp.Usage.PromptTokens
+		reply.Metrics.OutputTokens = openAIResp.Usage.CompletionTokens
+	}
+	reply.Metrics.Model = c.Model
+	timer.fill(&reply.Metrics)
+	return reply, nil
+}
+
+// post sends a chat completion request for the given messages.
+// Options without an OpenAI equivalent (top_k, num_ctx) are not sent.
+func (c *OpenAIClient) post(ctx context.Context, messages []Message, stream bool, format *openAIResponseFormat) (*http.Response, error) {
+	reqBody := openAIRequest{
+		Model:       c.Model,
+		Messages:    openAIMessages(withSystem(c.System, messages)),
+		Stream:      stream,
+		Temperature: c.Options.Temperature,
+		TopP:        c.Options.TopP,
+		Seed:        c.Options.Seed,
+		MaxTokens:   c.Options.NumPredict,
+		Stop:        c.Options.Stop,
+
+		ResponseFormat: format,
+	}
+	if stream {
+		reqBody.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+
+	return c.send(ctx, http.MethodPost, "/chat/completions", reqBody)
+}
+
+// openAIMessages converts messages to the request format, sending images
+// inline as data URLs. Tool calls carry no IDs, so they're numbered and the
+// tool results that follow answer them in order.
+func openAIMessages(messages []Message) []openAIMessage {
+	result := make([]openAIMessage, 0, len(messages))
+	var calls int
+	var pending []string
+	for _, msg := range messages {
+		switch {
+		case len(msg.ToolCalls) > 0:
+			out := openAIMessage{Role: msg.Role, Content: msg.Content}
+			pending = pending[:0]
+			for _, call := range msg.ToolCalls {
+				calls++
+				tc := openAIToolCall{ID: fmt.Sprintf("call_%d", calls), Type: "function"}
+				tc.Function.Name = call.Function.Name
+				tc.Function.Arguments = "{}"
+				if call.Function.Arguments != nil {
+					args, _ := json.Marshal(call.Function.Arguments)
+					tc.Function.Arguments = string(args)
+				}
+				out.ToolCalls = append(out.ToolCalls, tc)
+				pending = append(pending, tc.ID)
+			}
+			result = append(result, out)
+			continue
+		case msg.Role == RoleTool && len(pending) > 0:
+			result = append(result, openAIMessage{Role: RoleTool, Content: msg.Content, ToolCallID: pending[0]})
+			pending = pending[1:]
+			continue
+		case msg.Role == RoleTool:
+			// A result without its call would be refused, so it's told as text
+			result = append(result, openAIMessage{Role: RoleUser, Content: fmt.Sprintf("Result of the %s tool:\n%s", msg.ToolName, msg.Content)})
+			continue
+		}
+
+		if len(msg.Images) == 0 {
+			result = append(result, openAIMessage{Role: msg.Role, Content: msg.Content})
+			continue
+		}
+
+		parts := []openAIContentPart{{Type: "text", Text: msg.Content}}
+		for _, image := range msg.Images {
+			url := "data:" + http.DetectContentType(image) + ";base64," + base64.StdEncoding.EncodeToString(image)
+			parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: url}})
+		}
+		result = append(result, openAIMessage{Role: msg.Role, Content: parts})
+	}
+	return result
+}
+
+// send issues a request to the server, retrying transient failures.
+// Any answer other than 200 OK is converted into an *Error.
+func (c *OpenAIClient) send(ctx context.Context, method, path string, reqBody interface{}) (*http.Response, error) {
+	var jsonData []byte
+	if reqBody != nil {
+		var err error
+		if jsonData, err = json.Marshal(reqBody); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := doWithRetry(ctx, c.client, c.Retry, func() (*http.Request, error) {
+		var body io.Reader
+		if jsonData != nil {
+			body = bytes.NewReader(jsonData)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		c.setHeaders(req)
+		return req, nil
+	})
+	if err != nil {
+		return nil, transportError(ctx, err, fmt.Sprintf("openai-compatible server not reachable at %s", c.BaseURL))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+
+		msg := strings.TrimSpace(string(body))
+		var openAIResp openAIResponse
+		if json.Unmarshal(body, &openAIResp) == nil && openAIResp.Error != nil {
+			msg = openAIResp.Error.Message
+		}
+		return nil, c.responseError(resp.StatusCode, msg)
+	}
+
+	return resp, nil
+}
+
+// setHeaders adds the authorization header when an API key is configured,
+// followed by any custom headers
+func (c *OpenAIClient) setHeaders(req *http.Request) {
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	setHeaders(req, c.Headers)
+}
+
+// responseError converts an error returned by the server into an *Error.
+// status is 0 for errors reported in the middle of a stream.
+func (c *OpenAIClient) responseError(status int, msg string) error {
+	switch kind := responseKind(status, msg); kind {
+	case ErrModelNotFound:
+		return &Error{Kind: kind, Status: status, Message: fmt.Sprintf("model '%s' not found on %s: %s", c.Model, c.BaseURL, msg)}
+	case ErrContextOverflow:
+		return contextOverflowError(msg, status)
+	default:
+		return &Error{Kind: kind, Status: status, Message: fmt.Sprintf("openai error: %s", msg)}
+	}
+}
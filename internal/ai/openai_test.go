@@ -0,0 +1,143 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newOpenAITestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var req openAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if len(req.Messages) == 0 {
+			t.Errorf("request has no messages")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if !req.Stream {
+			if req.ResponseFormat == nil {
+				t.Errorf("command request has no response_format")
+			}
+			answer, _ := json.Marshal(Command{Command: "`ls -la`", Explanation: "List files", Risk: RiskLow})
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q}}]}`, answer)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{"Hel", "lo", "!"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":3}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"id":"qwen2.5-coder","created":1700000000}]}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOpenAIClient(t *testing.T) {
+	srv := newOpenAITestServer(t)
+	client := NewOpenAIClient(Config{BaseURL: srv.URL + "/v1/", Model: "qwen2.5-coder"})
+	ctx := context.Background()
+
+	cmd, err := client.GenerateCommand(ctx, "list files")
+	if err != nil {
+		t.Fatalf("GenerateCommand: %v", err)
+	}
+	if cmd.Command != "ls -la" || cmd.Risk != RiskLow {
+		t.Fatalf("GenerateCommand = %+v, want low-risk %q", cmd, "ls -la")
+	}
+
+	var chunks []string
+	reply, err := client.ChatStream(ctx, []Message{{Role: RoleUser, Content: "hi"}}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if got := strings.Join(chunks, ""); got != "Hello!" || reply.Content != got {
+		t.Fatalf("ChatStream = %q (reply %q), want %q", got, reply.Content, "Hello!")
+	}
+	if reply.Metrics.OutputTokens != 3 {
+		t.Fatalf("ChatStream output tokens = %d, want 3", reply.Metrics.OutputTokens)
+	}
+
+	models, err := client.ListModels(ctx)
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(models) != 1 || models[0].Name != "qwen2.5-coder" {
+		t.Fatalf("ListModels = %+v, want one qwen2.5-coder model", models)
+	}
+}
+
+func TestOpenAIClientError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"message":"model not loaded"}}`)
+	}))
+	defer srv.Close()
+
+	client := NewOpenAIClient(Config{BaseURL: srv.URL, Model: "missing"})
+	_, err := client.Chat(context.Background(), []Message{{Role: RoleUser, Content: "hi"}})
+	if err == nil || !strings.Contains(err.Error(), "model not loaded") {
+		t.Fatalf("Chat error = %v, want server message", err)
+	}
+	if !errors.Is(err, ErrModelNotFound) {
+		t.Fatalf("Chat error = %v, want ErrModelNotFound", err)
+	}
+}
+
+func TestOpenAIMessagesWithTools(t *testing.T) {
+	t.Parallel()
+
+	messages := []Message{
+		// Left behind when its call was summarised away
+		{Role: RoleTool, ToolName: "read_file", Content: "old"},
+		{Role: RoleUser, Content: "what's in there?"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{
+			{Function: ToolCallFunction{Name: "list_dir", Arguments: map[string]interface{}{"path": "."}}},
+			{Function: ToolCallFunction{Name: "read_file"}},
+		}},
+		{Role: RoleTool, ToolName: "list_dir", Content: "notes.txt"},
+		{Role: RoleTool, ToolName: "read_file", Content: "hello"},
+		{Role: RoleAssistant, Content: "One file saying hello."},
+	}
+
+	got, err := json.Marshal(openAIMessages(messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"role":"user","content":"Result of the read_file tool:\nold"},` +
+		`{"role":"user","content":"what's in there?"},` +
+		`{"role":"assistant","content":"","tool_calls":[` +
+		`{"id":"call_1","type":"function","function":{"name":"list_dir","arguments":"{\"path\":\".\"}"}},` +
+		`{"id":"call_2","type":"function","function":{"name":"read_file","arguments":"{}"}}]},` +
+		`{"role":"tool","content":"notes.txt","tool_call_id":"call_1"},` +
+		`{"role":"tool","content":"hello","tool_call_id":"call_2"},` +
+		`{"role":"assistant","content":"One file saying hello."}]`
+	if string(got) != want {
+		t.Errorf("openAIMessages =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package ai
+
+// Options tunes how a model generates text. Nil fields leave the model's
+// own defaults in place.
+type Options struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	TopK        *int     `json:"top_k,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	NumCtx      *int     `json:"num_ctx,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// Merge returns o with every field that is set in override replaced
+func (o Options) Merge(override Options) Options {
+	if override.Temperature != nil {
+		o.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		o.TopP = override.TopP
+	}
+	if override.TopK != nil {
+		o.TopK = override.TopK
+	}
+	if override.Seed != nil {
+		o.Seed = override.Seed
+	}
+	if override.NumCtx != nil {
+		o.NumCtx = override.NumCtx
+	}
+	if override.NumPredict != nil {
+		o.NumPredict = override.NumPredict
+	}
+	if override.Stop != nil {
+		o.Stop = override.Stop
+	}
+	return o
+}
+
+// isZero reports whether no option is set
+func (o Options) isZero() bool {
+	return o.Temperature == nil && o.TopP == nil && o.TopK == nil && o.Seed == nil &&
+		o.NumCtx == nil && o.NumPredict == nil && len(o.Stop) == 0
+}
+
+// Float returns a pointer to v, for setting Options fields
+func Float(v float64) *float64 {
+	return &v
+}
+
+// Int returns a pointer to v, for setting Options fields
+func Int(v int) *int {
+	return &v
+}
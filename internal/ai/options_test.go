@@ -0,0 +1,47 @@
+package ai
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOptionsMerge(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		base     Options
+		override Options
+		expected string // The merged options as JSON
+	}{
+		{name: "empty", expected: `{}`},
+		{name: "defaults kept", base: Options{Temperature: Float(0.7)}, expected: `{"temperature":0.7}`},
+		{name: "override only", override: Options{Seed: Int(42)}, expected: `{"seed":42}`},
+		{
+			name:     "set fields win",
+			base:     Options{Temperature: Float(0.7), TopK: Int(40), Stop: []string{"\n"}},
+			override: Options{Temperature: Float(0.2), NumCtx: Int(8192)},
+			expected: `{"temperature":0.2,"top_k":40,"num_ctx":8192,"stop":["\n"]}`,
+		},
+		{
+			// Zero is a value like any other, not a missing option
+			name:     "zero overrides",
+			base:     Options{Temperature: Float(0.7), NumPredict: Int(100)},
+			override: Options{Temperature: Float(0), NumPredict: Int(0), TopP: Float(0)},
+			expected: `{"temperature":0,"top_p":0,"num_predict":0}`,
+		},
+		{
+			name:     "all fields",
+			base:     Options{Temperature: Float(1), TopP: Float(1), TopK: Int(1), Seed: Int(1), NumCtx: Int(1), NumPredict: Int(1), Stop: []string{"a"}},
+			override: Options{Temperature: Float(2), TopP: Float(2), TopK: Int(2), Seed: Int(2), NumCtx: Int(2), NumPredict: Int(2), Stop: []string{"b"}},
+			expected: `{"temperature":2,"top_p":2,"top_k":2,"seed":2,"num_ctx":2,"num_predict":2,"stop":["b"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		merged, _ := json.Marshal(tt.base.Merge(tt.override))
+		if string(merged) != tt.expected {
+			t.Errorf("%s: Merge = %s, want %s", tt.name, merged, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultModel is the model used when none is specified. It is a coder
+// model, used for command generation.
+const DefaultModel = "codellama:7b"
+
+// DefaultChatModel is the general model used for conversations
+const DefaultChatModel = "llama3.1:8b"
+
+// DefaultEmbeddingModel is the model used for embeddings when none is specified
+const DefaultEmbeddingModel = "nomic-embed-text"
+
+// Provider names accepted by NewProvider
+const (
+	ProviderOllama = "ollama"
+	ProviderOpenAI = "openai"
+)
+
+// Provider is implemented by every AI backend clai can talk to.
+// Cancelling ctx aborts the in-flight request, including a running stream.
+type Provider interface {
+	// GenerateCommand converts a natural language prompt into a bash command
+	GenerateCommand(ctx context.Context, prompt string) (*Command, error)
+	// Chat sends the conversation history and returns the assistant's reply (non-streaming)
+	Chat(ctx context.Context, messages []Message) (*Reply, error)
+	// ChatStream streams the assistant's reply to the conversation history, calling the
+	// callback for each chunk. The returned reply holds the full content and metrics.
+	ChatStream(ctx context.Context, messages []Message, callback func(string) error) (*Reply, error)
+	// ListModels returns the models available on the backend
+	ListModels(ctx context.Context) ([]Model, error)
+}
+
+// Embedder is implemented by providers that can turn text into vectors
+type Embedder interface {
+	// Embed returns one embedding vector per text, in the same order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// ModelManager is implemented by providers that can install and remove models
+type ModelManager interface {
+	// PullModel downloads a model, reporting progress as it goes
+	PullModel(ctx context.Context, name string, progress func(PullProgress)) error
+	// ShowModel returns details about an installed model
+	ShowModel(ctx context.Context, name string) (*ModelInfo, error)
+	// DeleteModel removes an installed model
+	DeleteModel(ctx context.Context, name string) error
+	// LoadModel loads a model into memory so the next request doesn't wait for it
+	LoadModel(ctx context.Context, name string) error
+}
+
+// Message roles understood by every provider
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool" // Result of a tool call
+)
+
+// Message is a single turn in a conversation
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images are raw image files for vision models (sent base64-encoded)
+	Images [][]byte `json:"images,omitempty"`
+	// ToolCalls are the tools an assistant message asks to call
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolName names the tool whose result a RoleTool message holds
+	ToolName string `json:"tool_name,omitempty"`
+	// Thinking is the reasoning of a thinking model, kept apart from Content
+	Thinking string `json:"thinking,omitempty"`
+}
+
+// Model describes a model available on a provider
+type Model struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size,omitempty"`
+	ModifiedAt time.Time `json:"modified_at,omitempty"`
+}
+
+// ModelInfo holds the details of an installed model
+type ModelInfo struct {
+	Name              string `json:"name"`
+	Family            string `json:"family,omitempty"`
+	ParameterSize     string `json:"parameter_size,omitempty"`
+	QuantizationLevel string `json:"quantization_level,omitempty"`
+	ContextLength     int    `json:"context_length,omitempty"`
+	Parameters        string `json:"parameters,omitempty"`
+	Template          string `json:"template,omitempty"`
+}
+
+// PullProgress reports the state of a model download
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// HasModel reports whether name appears in models, treating a missing tag as ":latest"
+func HasModel(models []Model, name string) bool {
+	for _, m := range models {
+		if m.Name == name || (!strings.Contains(name, ":") && m.Name == name+":latest") {
+			return true
+		}
+	}
+	return false
+}
+
+// Config selects and configures a Provider
+type Config struct {
+	Provider       string      // ProviderOllama (default) or ProviderOpenAI
+	BaseURL        string      // Server URL; empty uses the provider's default
+	Model          string      // Model name; empty uses DefaultModel
+	EmbeddingModel string      // Model used by Embed; empty uses DefaultEmbeddingModel
+	Headers        http.Header // Extra headers sent with every request, e.g. Authorization for a proxy
+	Timeouts       Timeouts    // Zero fields use DefaultTimeouts
+	Options        Options     // Generation options sent with every request
+	System         string      // System prompt; empty uses a built-in one for commands and none for chat
+	Retry          RetryPolicy // Zero fields use DefaultRetryPolicy
+
+	// Transport, if set, wraps the HTTP transport, e.g. Recorder.Wrap or Replayer.Wrap
+	Transport func(http.RoundTripper) http.RoundTripper
+
+	// KeepAlive is how long Ollama keeps the model loaded after a request,
+	// e.g. "10m", or "-1" to keep it loaded. Empty uses the server default.
+	KeepAlive string
+}
+
+// NewProvider creates the provider named in cfg
+func NewProvider(cfg Config) (Provider, error) {
+	switch cfg.Provider {
+	case "", ProviderOllama:
+		return NewOllamaClient(cfg), nil
+	case ProviderOpenAI:
+		return NewOpenAIClient(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (expected %q or %q)", cfg.Provider, ProviderOllama, ProviderOpenAI)
+	}
+}
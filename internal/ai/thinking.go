@@ -0,0 +1,176 @@
+package ai
+
+import (
+	"context"
+	"strings"
+)
+
+// Tags around the reasoning of models like deepseek-r1 and qwen3 that don't
+// use a separate thinking field
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+type thinkingKey struct{}
+
+// WithThinking returns a context whose streamed replies pass the model's
+// reasoning to callback as it arrives. Like httptrace.WithClientTrace, it
+// reaches through wrappers such as FallbackProvider unchanged.
+func WithThinking(ctx context.Context, callback func(string) error) context.Context {
+	return context.WithValue(ctx, thinkingKey{}, callback)
+}
+
+// thinkingCallback returns the callback set with WithThinking, or one that ignores the reasoning
+func thinkingCallback(ctx context.Context) func(string) error {
+	if callback, ok := ctx.Value(thinkingKey{}).(func(string) error); ok && callback != nil {
+		return callback
+	}
+	return func(string) error { return nil }
+}
+
+// withoutThinking returns messages with the reasoning of earlier replies
+// cleared. It isn't sent back to the model: it would only fill the context,
+// and isn't counted by EstimateTokens.
+func withoutThinking(messages []Message) []Message {
+	var result []Message
+	for i, msg := range messages {
+		if msg.Thinking == "" {
+			continue
+		}
+		if result == nil {
+			result = append([]Message(nil), messages...)
+		}
+		result[i].Thinking = ""
+	}
+	if result == nil {
+		return messages
+	}
+	return result
+}
+
+// SplitThinking separates a leading <think> block from a reply. A block that
+// is never closed holds the whole reply.
+func SplitThinking(text string) (thinking, content string) {
+	var s thinkSplitter
+	t1, c1 := s.write(text)
+	t2, c2 := s.flush()
+	return t1 + t2, c1 + c2
+}
+
+// thinkSplitter separates a leading <think> block from streamed text. Text
+// that may be the start of a tag is held back until the next chunk decides it.
+type thinkSplitter struct {
+	thinking bool   // Inside the block
+	started  bool   // Content has begun, so no block can open any more
+	pending  string // Held back text
+}
+
+// write splits the next chunk into reasoning and content
+func (s *thinkSplitter) write(chunk string) (thinking, content string) {
+	text := s.pending + chunk
+	s.pending = ""
+
+	var think, out strings.Builder
+	for text != "" {
+		if s.started {
+			out.WriteString(text)
+			break
+		}
+
+		if s.thinking {
+			if i := strings.Index(text, thinkClose); i >= 0 {
+				think.WriteString(text[:i])
+				text = text[i+len(thinkClose):]
+				s.thinking = false
+				continue
+			}
+			keep := partialSuffix(text, thinkClose)
+			think.WriteString(text[:len(text)-keep])
+			s.pending = text[len(text)-keep:]
+			break
+		}
+
+		// Whitespace before the block or between it and the content is dropped
+		text = strings.TrimLeft(text, " \t\r\n")
+		switch {
+		case text == "":
+		case strings.HasPrefix(text, thinkOpen):
+			text = text[len(thinkOpen):]
+			s.thinking = true
+		case strings.HasPrefix(thinkOpen, text):
+			s.pending = text
+			text = ""
+		default:
+			s.started = true
+		}
+	}
+	return think.String(), out.String()
+}
+
+// flush returns whatever is still held back once the stream has ended
+func (s *thinkSplitter) flush() (thinking, content string) {
+	pending := s.pending
+	s.pending = ""
+	if s.thinking {
+		return pending, ""
+	}
+	return "", pending
+}
+
+// partialSuffix returns the length of the longest suffix of text that is a
+// proper prefix of tag
+func partialSuffix(text, tag string) int {
+	for n := len(tag) - 1; n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// replyStream collects a streamed reply, passing content to callback and
+// reasoning, whether tagged or sent separately, to the thinking callback
+type replyStream struct {
+	callback func(string) error
+	onThink  func(string) error
+	split    thinkSplitter
+
+	content  strings.Builder
+	thinking strings.Builder
+}
+
+func newReplyStream(ctx context.Context, callback func(string) error) *replyStream {
+	return &replyStream{callback: callback, onThink: thinkingCallback(ctx)}
+}
+
+// write adds a chunk of the reply's text, which may contain a <think> block
+func (s *replyStream) write(chunk string) error {
+	return s.emit(s.split.write(chunk))
+}
+
+// think adds a chunk of reasoning the backend sent separately
+func (s *replyStream) think(chunk string) error {
+	return s.emit(chunk, "")
+}
+
+// close passes on any held back text
+func (s *replyStream) close() error {
+	return s.emit(s.split.flush())
+}
+
+func (s *replyStream) emit(thinking, content string) error {
+	if thinking != "" {
+		s.thinking.WriteString(thinking)
+		if err := s.onThink(thinking); err != nil {
+			return err
+		}
+	}
+	if content != "" {
+		s.content.WriteString(content)
+		if err := s.callback(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSplitThinking(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text     string
+		thinking string
+		content  string
+	}{
+		{text: "ls -la", content: "ls -la"},
+		{text: "<think>\nThe user wants files.\n</think>\n\nls -la", thinking: "\nThe user wants files.\n", content: "ls -la"},
+		{text: "  <think>hmm</think>ok", thinking: "hmm", content: "ok"},
+		{text: "<think>never closed", thinking: "never closed"},
+		{text: "use <think> tags", content: "use <think> tags"},
+		{text: "<table>", content: "<table>"},
+	}
+
+	for _, tt := range tests {
+		thinking, content := SplitThinking(tt.text)
+		if thinking != tt.thinking || content != tt.content {
+			t.Errorf("SplitThinking(%q) = %q, %q, want %q, %q", tt.text, thinking, content, tt.thinking, tt.content)
+		}
+	}
+}
+
+func TestReplyStreamSplitsTagsAcrossChunks(t *testing.T) {
+	var thought, streamed []string
+	ctx := WithThinking(context.Background(), func(chunk string) error {
+		thought = append(thought, chunk)
+		return nil
+	})
+	stream := newReplyStream(ctx, func(chunk string) error {
+		streamed = append(streamed, chunk)
+		return nil
+	})
+
+	for _, chunk := range []string{"<th", "ink>Let me ", "think</th", "ink>\n", "Hello", " there"} {
+		if err := stream.write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(thought, ""); got != "Let me think" || stream.thinking.String() != got {
+		t.Errorf("thinking = %q (collected %q), want %q", got, stream.thinking.String(), "Let me think")
+	}
+	if got := strings.Join(streamed, "|"); got != "Hello| there" {
+		t.Errorf("content chunks = %q, want Hello| there", got)
+	}
+}
+
+func TestOllamaChatLeavesThinkingOut(t *testing.T) {
+	var sent []Message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		sent = req.Messages
+		fmt.Fprint(w, `{"message":{"role":"assistant","thinking":"Field.","content":"<think>Tags.</think>Answer"},"done":true}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	history := []Message{
+		{Role: RoleUser, Content: "hi"},
+		{Role: RoleAssistant, Content: "Hello!", Thinking: strings.Repeat("long reasoning ", 100)},
+		{Role: RoleUser, Content: "how do I list files?"},
+	}
+	reply, err := NewOllamaClient(Config{BaseURL: srv.URL}).Chat(context.Background(), history)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if reply.Content != "Answer" || reply.Thinking != "Field.\nTags." {
+		t.Errorf("reply = %+v, want both parts of the reasoning on separate lines", reply)
+	}
+	if len(sent) != len(history) || sent[1].Thinking != "" || sent[1].Content != "Hello!" {
+		t.Errorf("sent messages = %+v, want the earlier reasoning left out", sent)
+	}
+	if EstimateTokens(sent) != EstimateTokens(history) {
+		t.Errorf("EstimateTokens(sent) = %d, want %d as estimated for the history", EstimateTokens(sent), EstimateTokens(history))
+	}
+	if history[1].Thinking == "" {
+		t.Error("the caller's history was modified")
+	}
+}
@@ -0,0 +1,35 @@
+package ai
+
+import "context"
+
+// ToolCaller is implemented by providers whose models can call tools
+type ToolCaller interface {
+	// ChatStreamTools streams the reply like ChatStream, offering tools the model
+	// may call. Requested calls are returned in Reply.ToolCalls; answer each with a
+	// RoleTool message and send the conversation again to get the final reply.
+	ChatStreamTools(ctx context.Context, messages []Message, tools []Tool, callback func(string) error) (*Reply, error)
+}
+
+// Tool describes a function the model may ask to call
+type Tool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Parameters  interface{} `json:"parameters"` // JSON schema of the arguments
+}
+
+// ToolCall is a model's request to call a tool
+type ToolCall struct {
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction names the tool to call and its arguments
+type ToolCallFunction struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// StringArg returns a string argument of the call, or "" when missing
+func (c ToolCall) StringArg(name string) string {
+	s, _ := c.Function.Arguments[name].(string)
+	return s
+}
@@ -0,0 +1,220 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Timeouts bounds the phases of a request to an AI backend
+type Timeouts struct {
+	Connect    time.Duration // Dialing the server
+	FirstToken time.Duration // Waiting for the first chunk of a streamed response
+	Total      time.Duration // The whole request, including reading a stream
+}
+
+// DefaultTimeouts are used for any Timeouts field left at zero
+var DefaultTimeouts = Timeouts{
+	Connect:    10 * time.Second,
+	FirstToken: 60 * time.Second,
+	Total:      5 * time.Minute,
+}
+
+// withDefaults fills zero fields from DefaultTimeouts
+func (t Timeouts) withDefaults() Timeouts {
+	if t.Connect <= 0 {
+		t.Connect = DefaultTimeouts.Connect
+	}
+	if t.FirstToken <= 0 {
+		t.FirstToken = DefaultTimeouts.FirstToken
+	}
+	if t.Total <= 0 {
+		t.Total = DefaultTimeouts.Total
+	}
+	return t
+}
+
+// newHTTPClient builds an HTTP client enforcing the connect and total
+// timeouts; streams enforce FirstToken themselves with startFirstTokenTimer.
+// wrap, if set, wraps the transport, e.g. to record or replay a cassette.
+func newHTTPClient(timeouts Timeouts, wrap func(http.RoundTripper) http.RoundTripper) *http.Client {
+	timeouts = timeouts.withDefaults()
+
+	dialer := &net.Dialer{
+		Timeout:   timeouts.Connect,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+
+	var rt http.RoundTripper = transport
+	if wrap != nil {
+		rt = wrap(transport)
+	}
+
+	return &http.Client{
+		Transport: rt,
+		Timeout:   timeouts.Total,
+	}
+}
+
+// firstTokenTimer cancels a streamed request whose first chunk doesn't arrive
+// in time. The response headers tell nothing: Ollama sends them with the first
+// chunk, OpenAI-compatible servers before the model has produced anything.
+type firstTokenTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired atomic.Bool
+}
+
+// startFirstTokenTimer returns a context for a streamed request that is
+// cancelled unless received is called within timeout. Zero disables the timer.
+func startFirstTokenTimer(ctx context.Context, timeout time.Duration) (context.Context, *firstTokenTimer) {
+	ctx, cancel := context.WithCancel(ctx)
+	t := &firstTokenTimer{timeout: timeout, cancel: cancel}
+	if timeout > 0 {
+		t.timer = time.AfterFunc(timeout, func() {
+			t.expired.Store(true)
+			cancel()
+		})
+	}
+	return ctx, t
+}
+
+// received stops the timer once the first chunk has arrived
+func (t *firstTokenTimer) received() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// stop releases the timer and its context, turning err into ErrTimeout when
+// it was caused by the timer
+func (t *firstTokenTimer) stop(err error) error {
+	t.received()
+	t.cancel()
+	if err != nil && t.expired.Load() {
+		return &Error{
+			Kind:    ErrTimeout,
+			Message: fmt.Sprintf("no response within %s (raise --first-token-timeout for slow models)", t.timeout),
+			Err:     err,
+		}
+	}
+	return err
+}
+
+// setHeaders copies the configured custom headers onto req, replacing any
+// header of the same name
+func setHeaders(req *http.Request, headers http.Header) {
+	for name, values := range headers {
+		req.Header.Del(name)
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+}
+
+// RetryPolicy controls how transient failures are retried
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first; 1 disables retries
+	BaseDelay   time.Duration // Delay before the first retry, doubled for each further retry
+	MaxDelay    time.Duration // Upper bound for a single delay
+}
+
+// DefaultRetryPolicy is used for any RetryPolicy field left at zero
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// withDefaults fills zero fields from DefaultRetryPolicy
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	return p
+}
+
+// backoff returns the delay before retry number n (starting at 1): a random
+// duration between half and all of BaseDelay*2^(n-1), capped at MaxDelay
+func (p RetryPolicy) backoff(n int) time.Duration {
+	delay := p.BaseDelay << (n - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// doWithRetry sends the request built by newReq, retrying connection failures
+// and 429/502/503/504 answers with jittered exponential backoff. Timeouts are
+// not retried. newReq is called for every attempt so the body can be re-sent.
+func doWithRetry(ctx context.Context, client *http.Client, policy RetryPolicy, newReq func() (*http.Request, error)) (*http.Response, error) {
+	policy = policy.withDefaults()
+
+	for attempt := 1; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+
+		last := attempt >= policy.MaxAttempts
+		resp, err := client.Do(req)
+		switch {
+		case err != nil:
+			if last || !retryableError(ctx, err) {
+				return nil, err
+			}
+		case retryableStatus(resp.StatusCode) && !last:
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryableError reports whether a failed round trip is worth retrying
+func retryableError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+	return true
+}
+
+// retryableStatus reports whether a response status signals a transient failure
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
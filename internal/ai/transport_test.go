@@ -0,0 +1,157 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoWithRetry(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	resp, err := doWithRetry(ctx, srv.Client(), policy, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	})
+	if err != nil {
+		t.Fatalf("doWithRetry: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || attempts != 3 {
+		t.Fatalf("got status %d after %d attempts, want 200 after 3", resp.StatusCode, attempts)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for n, max := range map[int]time.Duration{1: 100, 2: 200, 3: 300, 4: 300} {
+		max *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if d := policy.backoff(n); d < max/2 || d > max {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", n, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestFirstTokenTimeout(t *testing.T) {
+	timeouts := Timeouts{FirstToken: 100 * time.Millisecond, Total: 5 * time.Second}
+
+	// delay answers after d, then streams a second chunk after another d
+	delay := func(d time.Duration, first, second string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush() // Headers first, like OpenAI-compatible servers
+			time.Sleep(d)
+			fmt.Fprintln(w, first)
+			w.(http.Flusher).Flush()
+			if second != "" {
+				time.Sleep(d)
+				fmt.Fprintln(w, second)
+			}
+		}
+	}
+	ollamaReply := `{"message":{"role":"assistant","content":"Hello"},"done":true}`
+	ollamaChunks := []string{`{"message":{"role":"assistant","content":"Hel"},"done":false}`, `{"message":{"role":"assistant","content":"lo"},"done":true}`}
+	openAIReply := `{"choices":[{"message":{"role":"assistant","content":"Hello"}}]}`
+	openAIChunks := []string{"data: " + `{"choices":[{"delta":{"content":"Hel"}}]}`, "data: " + `{"choices":[{"delta":{"content":"lo"}}]}` + "\n\ndata: [DONE]"}
+	messages := []Message{{Role: RoleUser, Content: "hi"}}
+	ignore := func(string) error { return nil }
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		call    func(ctx context.Context, cfg Config) (*Reply, error)
+		wantErr bool
+	}{
+		{
+			// Ollama sends the headers of an answer that isn't streamed only once it's done
+			name:    "ollama chat slower than the first-token timeout",
+			handler: delay(300*time.Millisecond, ollamaReply, ""),
+			call: func(ctx context.Context, cfg Config) (*Reply, error) {
+				return NewOllamaClient(cfg).Chat(ctx, messages)
+			},
+		},
+		{
+			name:    "openai chat slower than the first-token timeout",
+			handler: delay(300*time.Millisecond, openAIReply, ""),
+			call: func(ctx context.Context, cfg Config) (*Reply, error) {
+				return NewOpenAIClient(cfg).Chat(ctx, messages)
+			},
+		},
+		{
+			name:    "ollama stream with a late first chunk",
+			handler: delay(300*time.Millisecond, ollamaChunks[0], ollamaChunks[1]),
+			call: func(ctx context.Context, cfg Config) (*Reply, error) {
+				return NewOllamaClient(cfg).ChatStream(ctx, messages, ignore)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "openai stream with a late first chunk",
+			handler: delay(300*time.Millisecond, `data: {"choices":[{"delta":{"role":"assistant"}}]}`+"\n\n"+openAIChunks[0], openAIChunks[1]),
+			call: func(ctx context.Context, cfg Config) (*Reply, error) {
+				return NewOpenAIClient(cfg).ChatStream(ctx, messages, ignore)
+			},
+			wantErr: true,
+		},
+		{
+			// Only the first chunk is bounded, not the whole stream
+			name:    "ollama stream longer than the first-token timeout",
+			handler: delay(60*time.Millisecond, ollamaChunks[0], ollamaChunks[1]),
+			call: func(ctx context.Context, cfg Config) (*Reply, error) {
+				return NewOllamaClient(cfg).ChatStream(ctx, messages, ignore)
+			},
+		},
+		{
+			name:    "openai stream longer than the first-token timeout",
+			handler: delay(60*time.Millisecond, openAIChunks[0], openAIChunks[1]),
+			call: func(ctx context.Context, cfg Config) (*Reply, error) {
+				return NewOpenAIClient(cfg).ChatStream(ctx, messages, ignore)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			srv := httptest.NewServer(tt.handler)
+			t.Cleanup(srv.Close)
+
+			cfg := Config{BaseURL: srv.URL, Timeouts: timeouts, Retry: RetryPolicy{MaxAttempts: 1}}
+			reply, err := tt.call(context.Background(), cfg)
+			if tt.wantErr {
+				if !errors.Is(err, ErrTimeout) || !strings.Contains(err.Error(), "--first-token-timeout") {
+					t.Errorf("error = %v, want the first-token timeout", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v, want the reply", err)
+			}
+			if reply.Content != "Hello" {
+				t.Errorf("reply = %q, want Hello", reply.Content)
+			}
+		})
+	}
+}
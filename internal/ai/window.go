@@ -0,0 +1,164 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultContextTokens is the context window assumed when none is configured
+const DefaultContextTokens = 4096
+
+// Context strategies for conversations that outgrow the context window
+const (
+	ContextSummarize = "summarize" // Fold old turns into a rolling summary
+	ContextDrop      = "drop"      // Leave old turns out
+)
+
+const (
+	// A conversation is compacted once it fills compactAt of the window,
+	// down to compactTo so that it doesn't need compacting on every turn
+	compactAt = 0.75
+	compactTo = 0.5
+
+	// keepRecent is the number of latest messages that are always sent as they are
+	keepRecent = 4
+
+	// imageTokens is a rough cost of one image for vision models
+	imageTokens = 768
+)
+
+// EstimateTokens roughly counts the tokens messages take up, at about four
+// characters a token. It errs on the high side for code and non-English text.
+func EstimateTokens(messages []Message) int {
+	tokens := 0
+	for _, msg := range messages {
+		tokens += 4 + utf8.RuneCountInString(msg.Content)/4 + len(msg.Images)*imageTokens
+		if len(msg.ToolCalls) > 0 {
+			calls, _ := json.Marshal(msg.ToolCalls)
+			tokens += len(calls) / 4
+		}
+	}
+	return tokens
+}
+
+// ContextManager keeps a conversation within the model's context window.
+// Past a threshold it summarises the oldest turns with Summarizer into a
+// rolling summary, or leaves them out when Summarizer is nil.
+type ContextManager struct {
+	MaxTokens  int      // Zero uses DefaultContextTokens
+	Summarizer Provider // Model that writes the summary; nil drops old turns
+}
+
+// Window is the part of a conversation sent to the model
+type Window struct {
+	Messages   []Message // The summary, if any, followed by the recent messages
+	Summary    string    // Rolling summary of the messages left out
+	Summarized int       // Number of messages at the start of the history left out
+}
+
+// Fit returns the messages to send for history, given the summary of its first
+// summarized messages from an earlier call (empty and 0 for a new conversation).
+// Store the returned Summary and Summarized to resume the conversation later.
+// If summarising fails, the old turns are dropped instead.
+func (m *ContextManager) Fit(ctx context.Context, summary string, summarized int, history []Message) (*Window, error) {
+	// A shorter history than was summarised means messages were deleted since
+	if summarized < 0 || summarized > len(history) {
+		summary, summarized = "", 0
+	}
+
+	maxTokens := m.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultContextTokens
+	}
+
+	w := &Window{Summary: summary, Summarized: summarized}
+	if EstimateTokens(withSummary(summary, history[summarized:])) <= int(float64(maxTokens)*compactAt) {
+		w.Messages = withSummary(summary, history[summarized:])
+		return w, nil
+	}
+
+	cut := summarized
+	for cut < len(history)-keepRecent && EstimateTokens(withSummary(summary, history[cut:])) > int(float64(maxTokens)*compactTo) {
+		cut++
+	}
+	// Tool results make no sense without the call that asked for them
+	for cut < len(history)-1 && history[cut].Role == RoleTool {
+		cut++
+	}
+
+	if cut > summarized {
+		if m.Summarizer != nil {
+			next, err := m.summarize(ctx, summary, history[summarized:cut])
+			if err != nil && ctx.Err() != nil {
+				return nil, err
+			}
+			if err == nil {
+				w.Summary = next
+			}
+		}
+		w.Summarized = cut
+	}
+	w.Messages = withSummary(w.Summary, history[w.Summarized:])
+	return w, nil
+}
+
+// summarize folds messages into the summary of the conversation before them
+func (m *ContextManager) summarize(ctx context.Context, summary string, messages []Message) (string, error) {
+	var prompt strings.Builder
+	if summary != "" {
+		fmt.Fprintf(&prompt, "Summary of the conversation so far:\n%s\n\n", summary)
+	}
+	prompt.WriteString("Conversation to add to the summary:\n")
+	for _, msg := range messages {
+		switch {
+		case msg.Role == RoleTool:
+			fmt.Fprintf(&prompt, "Tool %s returned: %s\n", msg.ToolName, truncateRunes(msg.Content, 2000))
+		case len(msg.ToolCalls) > 0:
+			calls, _ := json.Marshal(msg.ToolCalls)
+			fmt.Fprintf(&prompt, "%s: %s\nAssistant called tools: %s\n", roleLabel(msg.Role), msg.Content, calls)
+		default:
+			fmt.Fprintf(&prompt, "%s: %s\n", roleLabel(msg.Role), msg.Content)
+		}
+	}
+
+	reply, err := m.Summarizer.Chat(ctx, []Message{
+		{Role: RoleSystem, Content: "You condense conversations between a user and an assistant. " +
+			"Write a short summary that keeps the facts, decisions, names, file paths, commands and open questions " +
+			"needed to carry on the conversation. Reply with the summary only."},
+		{Role: RoleUser, Content: prompt.String()},
+	})
+	if err != nil {
+		return "", fmt.Errorf("summarize conversation: %w", err)
+	}
+	return strings.TrimSpace(reply.Content), nil
+}
+
+// withSummary prepends the summary to messages. It is a user message, since
+// providers only add their system prompt when the conversation has none.
+func withSummary(summary string, messages []Message) []Message {
+	if summary == "" {
+		return messages
+	}
+	result := make([]Message, 0, len(messages)+1)
+	result = append(result, Message{Role: RoleUser, Content: "Summary of our conversation so far:\n" + summary})
+	return append(result, messages...)
+}
+
+// roleLabel names the author of a message in a transcript
+func roleLabel(role string) string {
+	if role == RoleAssistant {
+		return "Assistant"
+	}
+	return "User"
+}
+
+// truncateRunes shortens s to at most n runes
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// longHistory returns n alternating user and assistant messages of about 100 tokens each
+func longHistory(n int) []Message {
+	history := make([]Message, n)
+	for i := range history {
+		role := RoleUser
+		if i%2 == 1 {
+			role = RoleAssistant
+		}
+		history[i] = Message{Role: role, Content: fmt.Sprintf("message %d %s", i, strings.Repeat("x", 400))}
+	}
+	return history
+}
+
+func TestContextManagerDrops(t *testing.T) {
+	t.Parallel()
+
+	m := &ContextManager{MaxTokens: 1000}
+
+	short := longHistory(4)
+	w, err := m.Fit(context.Background(), "", 0, short)
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if w.Summarized != 0 || len(w.Messages) != len(short) {
+		t.Errorf("short history: window = %+v, want it sent as is", w)
+	}
+
+	history := longHistory(20)
+	w, err = m.Fit(context.Background(), "", 0, history)
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if w.Summarized == 0 || w.Summary != "" {
+		t.Fatalf("Summarized = %d, Summary = %q, want old messages dropped without a summary", w.Summarized, w.Summary)
+	}
+	if got := EstimateTokens(w.Messages); got > 500 {
+		t.Errorf("window holds ~%d tokens, want at most half the context", got)
+	}
+	if last := w.Messages[len(w.Messages)-1]; last.Content != history[len(history)-1].Content {
+		t.Errorf("last message = %q, want the latest one", last.Content)
+	}
+
+	// A deleted message invalidates what was summarised
+	w, err = m.Fit(context.Background(), "old", 30, short)
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if w.Summarized != 0 || w.Summary != "" || len(w.Messages) != len(short) {
+		t.Errorf("window = %+v, want a fresh start", w)
+	}
+}
+
+func TestContextManagerSummarizes(t *testing.T) {
+	var prompts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+		fmt.Fprintf(w, `{"message":{"role":"assistant","content":"summary %d"},"done":true}`, len(prompts))
+	}))
+	t.Cleanup(srv.Close)
+
+	m := &ContextManager{MaxTokens: 1000, Summarizer: NewOllamaClient(Config{BaseURL: srv.URL})}
+	history := longHistory(20)
+
+	w, err := m.Fit(context.Background(), "", 0, history)
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if w.Summary != "summary 1" || w.Summarized == 0 {
+		t.Fatalf("window = %+v, want old messages folded into the summary", w)
+	}
+	if first := w.Messages[0]; first.Role != RoleUser || !strings.HasSuffix(first.Content, "summary 1") {
+		t.Errorf("first message = %+v, want the summary", first)
+	}
+
+	// Resuming with the stored summary only summarises what was added since
+	history = longHistory(26)
+	w, err = m.Fit(context.Background(), w.Summary, w.Summarized, history)
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if w.Summary != "summary 2" || len(prompts) != 2 {
+		t.Fatalf("window = %+v after %d requests, want a second summary", w, len(prompts))
+	}
+	if !strings.Contains(prompts[1], "summary 1") || strings.Contains(prompts[1], "message 0 ") {
+		t.Errorf("second prompt = %q, want the earlier summary instead of the old messages", prompts[1])
+	}
+}
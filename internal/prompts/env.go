@@ -0,0 +1,100 @@
+package prompts
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KnownTools are the command-line tools whose presence is reported to the model,
+// so it neither avoids handy tools nor suggests missing ones
+var KnownTools = []string{"git", "rg", "fd", "jq", "yq", "fzf", "docker", "kubectl"}
+
+// probeTimeout bounds each command run to inspect the environment
+const probeTimeout = 2 * time.Second
+
+var (
+	// The coreutils and installed tools don't change while clai runs
+	toolsOnce sync.Once
+	coreutils string
+	installed []string
+	missing   []string
+)
+
+// detectStatic fills in the parts of vars that are looked up once per process
+func detectStatic(vars *Vars) {
+	toolsOnce.Do(func() {
+		coreutils = detectCoreutils()
+		installed, missing = detectTools(KnownTools)
+	})
+	vars.Coreutils = coreutils
+	vars.Tools = installed
+	vars.MissingTools = missing
+}
+
+// detectCoreutils tells GNU coreutils from the BSD ones of macOS and the BSDs
+// and from BusyBox, by asking ls for its version. It returns "" on Windows.
+func detectCoreutils() string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	out, _ := exec.CommandContext(ctx, "ls", "--version").CombinedOutput()
+	switch {
+	case strings.Contains(string(out), "GNU coreutils"), strings.Contains(string(out), "uutils"):
+		return "GNU" // uutils is a GNU-compatible rewrite
+	case strings.Contains(string(out), "BusyBox"):
+		return "BusyBox"
+	default:
+		return "BSD" // BSD ls has no --version
+	}
+}
+
+// detectTools splits tools into those found on the PATH and the others
+func detectTools(tools []string) (found, notFound []string) {
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err == nil {
+			found = append(found, tool)
+		} else {
+			notFound = append(notFound, tool)
+		}
+	}
+	return found, notFound
+}
+
+// detectGit returns the branch checked out in dir and whether it has
+// uncommitted changes. The branch is empty outside a git repository.
+func detectGit(dir string) (branch string, dirty bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "status", "--porcelain", "--branch").Output()
+	if err != nil {
+		return "", false
+	}
+	return parseGitStatus(string(out))
+}
+
+// parseGitStatus reads the output of git status --porcelain --branch
+func parseGitStatus(out string) (branch string, dirty bool) {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	header, ok := strings.CutPrefix(lines[0], "## ")
+	if !ok {
+		return "", false
+	}
+
+	switch {
+	case strings.HasPrefix(header, "No commits yet on "):
+		branch = strings.TrimPrefix(header, "No commits yet on ")
+	case strings.HasPrefix(header, "HEAD (no branch)"):
+		branch = "HEAD (detached)"
+	default:
+		branch, _, _ = strings.Cut(header, "...")
+		branch, _, _ = strings.Cut(branch, " ")
+	}
+	return branch, len(lines) > 1
+}
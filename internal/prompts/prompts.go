@@ -0,0 +1,128 @@
+// Package prompts loads the system prompt templates used by clai's subcommands
+package prompts
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaults are the built-in templates, used when the prompts directory has no override
+//
+//go:embed defaults/*.tmpl
+var defaults embed.FS
+
+// Vars are the values available to templates, e.g. {{.OS}} or {{.Cwd}}
+type Vars struct {
+	OS    string // runtime.GOOS, e.g. "linux" or "darwin"
+	Arch  string // runtime.GOARCH, e.g. "amd64" or "arm64"
+	Shell string // Name of the user's shell from $SHELL, e.g. "zsh"
+	Cwd   string // Current working directory
+	User  string // Login name of the current user
+	Date  string // Today's date as YYYY-MM-DD
+
+	Coreutils    string   // "GNU", "BSD" or "BusyBox"; empty if unknown
+	GitBranch    string   // Branch checked out in Cwd; empty outside a git repository
+	GitDirty     bool     // Whether the git repository has uncommitted changes
+	Tools        []string // KnownTools found on the PATH
+	MissingTools []string // KnownTools that aren't installed
+}
+
+// CurrentVars returns the template values for the current process
+func CurrentVars() Vars {
+	vars := Vars{
+		OS:    runtime.GOOS,
+		Arch:  runtime.GOARCH,
+		Shell: "sh",
+		Date:  time.Now().Format("2006-01-02"),
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		vars.Shell = filepath.Base(shell)
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		vars.Cwd = cwd
+		vars.GitBranch, vars.GitDirty = detectGit(cwd)
+	}
+	if u, err := user.Current(); err == nil {
+		vars.User = u.Username
+	} else {
+		vars.User = os.Getenv("USER")
+	}
+	detectStatic(&vars)
+	return vars
+}
+
+// Dir returns the directory holding user templates, ~/.config/clai/prompts
+// (or $XDG_CONFIG_HOME/clai/prompts, %APPDATA%\clai\prompts on Windows)
+func Dir() (string, error) {
+	var configDir string
+
+	switch runtime.GOOS {
+	case "windows":
+		configDir = os.Getenv("APPDATA")
+		if configDir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			configDir = filepath.Join(home, "AppData", "Roaming")
+		}
+	default: // linux, darwin
+		configDir = os.Getenv("XDG_CONFIG_HOME")
+		if configDir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			configDir = filepath.Join(home, ".config")
+		}
+	}
+
+	return filepath.Join(configDir, "clai", "prompts"), nil
+}
+
+// Load returns the source of the template called name: <Dir>/<name>.tmpl if
+// it exists, otherwise the built-in default
+func Load(name string) (string, error) {
+	if dir, err := Dir(); err == nil {
+		data, err := os.ReadFile(filepath.Join(dir, name+".tmpl"))
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("read prompt template: %w", err)
+		}
+	}
+
+	data, err := defaults.ReadFile("defaults/" + name + ".tmpl")
+	if err != nil {
+		return "", fmt.Errorf("prompt template %q not found (add %s.tmpl to the prompts directory)", name, name)
+	}
+	return string(data), nil
+}
+
+// funcs are the functions available to templates besides the built-in ones
+var funcs = template.FuncMap{"join": strings.Join}
+
+// Render executes the template source with vars
+func Render(source string, vars Vars) (string, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Funcs(funcs).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("parse prompt template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("render prompt template: %w", err)
+	}
+	return string(bytes.TrimSpace(buf.Bytes())), nil
+}
@@ -0,0 +1,126 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("APPDATA", configDir)
+
+	builtin, err := Load("bash")
+	if err != nil {
+		t.Fatalf("Load(bash): %v", err)
+	}
+	if !strings.Contains(builtin, "{{.Shell}}") {
+		t.Errorf("built-in bash template = %q, want it to use {{.Shell}}", builtin)
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		t.Fatalf("Dir: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bash.tmpl"), []byte("Terse commands for {{.OS}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	override, err := Load("bash")
+	if err != nil {
+		t.Fatalf("Load(bash) with override: %v", err)
+	}
+	if override != "Terse commands for {{.OS}}" {
+		t.Errorf("Load(bash) = %q, want the user's template", override)
+	}
+
+	if _, err := Load("missing"); err == nil {
+		t.Error("Load(missing) succeeded, want an error")
+	}
+}
+
+func TestRender(t *testing.T) {
+	vars := Vars{OS: "darwin", Shell: "zsh", Cwd: "/tmp", User: "ana", Date: "2025-01-02"}
+
+	got, err := Render("  {{.User}} uses {{.Shell}} on {{.OS}}\n", vars)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if want := "ana uses zsh on darwin"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+
+	if _, err := Render("{{.Hostname}}", vars); err == nil {
+		t.Error("Render with an unknown variable succeeded, want an error")
+	}
+}
+
+func TestRenderBashEnvironment(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	source, err := Load("bash")
+	if err != nil {
+		t.Fatalf("Load(bash): %v", err)
+	}
+
+	vars := Vars{
+		OS: "darwin", Shell: "zsh", Cwd: "/src/app", User: "ana", Date: "2025-01-02",
+		Coreutils: "BSD", GitBranch: "main", GitDirty: true,
+		Tools: []string{"git", "jq"}, MissingTools: []string{"rg", "fd"},
+	}
+	got, err := Render(source, vars)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	for _, want := range []string{
+		"BSD variants of coreutils that ship with macOS",
+		"Installed tools: git, jq.",
+		"don't use: rg, fd.",
+		"/src/app, a git repository on branch main with uncommitted changes, and the user is ana",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered bash prompt = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestParseGitStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		out    string
+		branch string
+		dirty  bool
+	}{
+		{out: "## main...origin/main\n", branch: "main"},
+		{out: "## main...origin/main [ahead 2]\n M go.mod\n?? notes.txt\n", branch: "main", dirty: true},
+		{out: "## feature\n", branch: "feature"},
+		{out: "## No commits yet on master\n?? README.md\n", branch: "master", dirty: true},
+		{out: "## HEAD (no branch)\n", branch: "HEAD (detached)"},
+		{out: "", branch: ""},
+	}
+
+	for _, tt := range tests {
+		branch, dirty := parseGitStatus(tt.out)
+		if branch != tt.branch || dirty != tt.dirty {
+			t.Errorf("parseGitStatus(%q) = %q, %v, want %q, %v", tt.out, branch, dirty, tt.branch, tt.dirty)
+		}
+	}
+}
+
+func TestDetectTools(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "jq"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	found, notFound := detectTools([]string{"jq", "rg"})
+	if strings.Join(found, ",") != "jq" || strings.Join(notFound, ",") != "rg" {
+		t.Errorf("detectTools = %v, %v, want jq found and rg missing", found, notFound)
+	}
+}
@@ -0,0 +1,592 @@
+// Package safety flags shell commands that can do lasting damage, such as
+// deleting outside the current directory or piping a download into a shell,
+// so that they can be confirmed more deliberately before they run
+package safety
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Danger is a part of a command that can do lasting damage
+type Danger struct {
+	Command string // The offending part of the command, e.g. "rm -rf ~"
+	Reason  string // What it would do, e.g. "recursively deletes your home directory"
+}
+
+// Check parses command as bash and returns its dangerous parts, in order.
+// Relative paths are resolved against cwd, following any cd in the command,
+// and "~" and $HOME against home. Recursive deletes are fine inside cwd. A
+// command that can't be parsed is reported too, since it can't be checked.
+func Check(command, cwd, home string) []Danger {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		return []Danger{{Command: command, Reason: fmt.Sprintf("can't be checked because it doesn't parse (%v)", err)}}
+	}
+
+	c := &checker{src: command, cwd: filepath.Clean(cwd), home: filepath.Clean(home)}
+	c.dir = c.cwd
+	c.stmts(file.Stmts)
+	return c.dangers
+}
+
+// checker collects the dangers found in one command
+type checker struct {
+	src, cwd, home string
+	dangers        []Danger
+
+	// dir is the directory the statement being checked runs in, which cd
+	// changes; empty once it can't be known without running the command
+	dir string
+}
+
+// stmts checks statements in the order they run
+func (c *checker) stmts(stmts []*syntax.Stmt) {
+	for _, stmt := range stmts {
+		c.stmt(stmt)
+	}
+}
+
+// stmt checks a statement, following the cd commands in it
+func (c *checker) stmt(stmt *syntax.Stmt) {
+	for _, r := range stmt.Redirs {
+		c.substitutions(r.Word)
+		c.checkRedirect(stmt, r)
+	}
+
+	switch cmd := stmt.Cmd.(type) {
+	case *syntax.CallExpr:
+		c.substitutions(cmd)
+		if !c.changeDir(cmd) {
+			c.checkCall(cmd)
+		}
+	case *syntax.BinaryCmd:
+		if cmd.Op == syntax.Pipe || cmd.Op == syntax.PipeAll {
+			if downloads(cmd.X) && isInterpreter(cmd.Y) {
+				c.add(cmd, "runs a script downloaded from the internet")
+			}
+			// Each side of a pipe runs in a subshell
+			c.subshell(func() { c.stmt(cmd.X) })
+			c.subshell(func() { c.stmt(cmd.Y) })
+			return
+		}
+		c.stmt(cmd.X)
+		c.stmt(cmd.Y)
+	case *syntax.Subshell:
+		c.subshell(func() { c.stmts(cmd.Stmts) })
+	case *syntax.Block:
+		c.stmts(cmd.Stmts)
+	case nil:
+	default:
+		// Loops, conditionals and the like may or may not run a cd in them
+		before := c.dir
+		syntax.Walk(cmd, func(node syntax.Node) bool {
+			switch n := node.(type) {
+			case *syntax.Stmt:
+				c.stmt(n)
+				return false
+			case *syntax.CmdSubst, *syntax.ProcSubst:
+				c.substitutions(n)
+				return false
+			}
+			return true
+		})
+		if c.dir != before {
+			c.dir = ""
+		}
+	}
+}
+
+// substitutions checks the commands substituted into node, e.g. $(...),
+// which run in a subshell
+func (c *checker) substitutions(node syntax.Node) {
+	if node == nil {
+		return
+	}
+	syntax.Walk(node, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.CmdSubst:
+			c.subshell(func() { c.stmts(n.Stmts) })
+			return false
+		case *syntax.ProcSubst:
+			c.subshell(func() { c.stmts(n.Stmts) })
+			return false
+		}
+		return true
+	})
+}
+
+// subshell runs check in a subshell, whose cd doesn't last
+func (c *checker) subshell(check func()) {
+	dir := c.dir
+	check()
+	c.dir = dir
+}
+
+// changeDir follows cd, pushd and popd, reporting whether call was one
+func (c *checker) changeDir(call *syntax.CallExpr) bool {
+	if len(call.Args) == 0 {
+		return false
+	}
+	switch call.Args[0].Lit() {
+	case "cd", "pushd":
+	case "popd":
+		c.dir = ""
+		return true
+	default:
+		return false
+	}
+
+	targets := operands(call.Args[1:])
+	switch {
+	case len(targets) == 0 && call.Args[0].Lit() == "cd":
+		c.dir = c.home
+	case len(targets) == 0 || targets[0].Lit() == "-":
+		c.dir = "" // The previous directory
+	default:
+		c.dir, _ = c.path(targets[0])
+	}
+	return true
+}
+
+// add records that node does what reason says, once
+func (c *checker) add(node syntax.Node, reason string) {
+	d := Danger{Command: c.src[node.Pos().Offset():node.End().Offset()], Reason: reason}
+	for _, seen := range c.dangers {
+		if seen == d {
+			return
+		}
+	}
+	c.dangers = append(c.dangers, d)
+}
+
+// checkRedirect flags output redirected into /etc
+func (c *checker) checkRedirect(stmt *syntax.Stmt, r *syntax.Redirect) {
+	switch r.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrClob, syntax.AppClob,
+		syntax.RdrAll, syntax.RdrAllClob, syntax.AppAll, syntax.AppAllClob:
+	default:
+		return
+	}
+	if path, ok := c.path(r.Word); ok && inEtc(path) {
+		c.add(stmt, fmt.Sprintf("writes to %s, a system configuration file", path))
+	}
+}
+
+// checkCall flags a dangerous simple command, looking through wrappers such as sudo
+func (c *checker) checkCall(call *syntax.CallExpr) {
+	name, args, fromStdin := unwrap(call.Args)
+	if name == nil {
+		return
+	}
+
+	switch cmd := filepath.Base(name.Lit()); {
+	case cmd == "rm":
+		c.checkRemove(call, args, fromStdin)
+	case cmd == "find":
+		c.checkFind(call, args)
+	case cmd == "dd":
+		for _, arg := range args {
+			if device, ok := strings.CutPrefix(arg.Lit(), "of="); ok && isDevice(device) {
+				c.add(call, fmt.Sprintf("overwrites the device %s", device))
+			}
+		}
+	case strings.HasPrefix(cmd, "mkfs") || diskTools[cmd]:
+		c.add(call, "formats or repartitions a disk")
+	case cmd == "diskutil" && len(args) > 0 && (strings.HasPrefix(args[0].Lit(), "erase") || strings.HasPrefix(args[0].Lit(), "partition")):
+		c.add(call, "erases or repartitions a disk")
+	case cmd == "chmod":
+		c.checkChmod(call, args)
+	case cmd == "git":
+		if isForcePush(args) {
+			c.add(call, "force pushes, rewriting history on the remote")
+		}
+	case cmd == "tee" || cmd == "truncate" || ((cmd == "sed" || cmd == "perl") && hasInPlace(args)):
+		for _, arg := range operands(args) {
+			if path, ok := c.path(arg); ok && inEtc(path) {
+				c.add(call, fmt.Sprintf("writes to %s, a system configuration file", path))
+			}
+		}
+	case cmd == "cp" || cmd == "mv" || cmd == "install" || cmd == "ln" || cmd == "rsync":
+		if ops := operands(args); len(ops) > 1 {
+			if path, ok := c.path(ops[len(ops)-1]); ok && inEtc(path) {
+				c.add(call, fmt.Sprintf("writes to %s, a system configuration file", path))
+			}
+		}
+	case shells[cmd] || cmd == "eval" || cmd == "source" || cmd == ".":
+		for _, arg := range args {
+			if downloads(arg) {
+				c.add(call, "runs a script downloaded from the internet")
+			}
+		}
+	}
+}
+
+// checkRemove flags recursive deletes of anything but the inside of the
+// current directory, and deletes in /etc
+func (c *checker) checkRemove(call *syntax.CallExpr, args []*syntax.Word, fromStdin bool) {
+	recursive := false
+	for _, arg := range options(args) {
+		opt := arg.Lit()
+		recursive = recursive || opt == "--recursive" ||
+			(!strings.HasPrefix(opt, "--") && strings.ContainsAny(opt, "rR"))
+	}
+
+	targets := operands(args)
+	if recursive && fromStdin && len(targets) == 0 {
+		c.add(call, "recursively deletes paths read from another command")
+	}
+	for _, target := range targets {
+		path, ok := c.path(target)
+		switch {
+		case !ok:
+			if recursive {
+				c.add(call, "recursively deletes a path that is only known when it runs")
+			}
+		case inEtc(path):
+			c.add(call, fmt.Sprintf("deletes %s, a system configuration file", path))
+		case !recursive:
+			// Deleting single files is left to the model's risk rating
+		case path == c.cwd:
+			c.add(call, "recursively deletes the current directory")
+		default:
+			c.checkRecursiveDelete(call, path)
+		}
+	}
+}
+
+// checkFind flags find deleting what it finds, with -delete or by running
+// rm, anywhere but inside the current directory, which is where find
+// searches without starting points
+func (c *checker) checkFind(call *syntax.CallExpr, args []*syntax.Word) {
+	// Options such as -L come first, then the starting points, then the expression
+	for len(args) > 0 {
+		if opt := args[0].Lit(); opt == "-D" && len(args) > 1 {
+			args = args[1:]
+		} else if opt != "-H" && opt != "-L" && opt != "-P" && !strings.HasPrefix(opt, "-O") {
+			break
+		}
+		args = args[1:]
+	}
+	var starts []*syntax.Word
+	for len(args) > 0 && !isFindExpression(args[0].Lit()) {
+		starts = append(starts, args[0])
+		args = args[1:]
+	}
+
+	if !findDeletes(args) {
+		return
+	}
+	for _, start := range starts {
+		path, ok := c.path(start)
+		switch {
+		case !ok:
+			c.add(call, "recursively deletes in a path that is only known when it runs")
+		case path == c.cwd:
+			// What it finds in the current directory is fine
+		default:
+			c.checkRecursiveDelete(call, path)
+		}
+	}
+}
+
+// checkRecursiveDelete flags recursively deleting path, unless it lies
+// inside the current directory
+func (c *checker) checkRecursiveDelete(call *syntax.CallExpr, path string) {
+	switch {
+	case path == "/" || path == "/*":
+		c.add(call, "recursively deletes the whole filesystem")
+	case path == c.home || path == filepath.Join(c.home, "*"):
+		c.add(call, "recursively deletes your home directory")
+	case !within(path, c.cwd):
+		c.add(call, fmt.Sprintf("recursively deletes %s, outside the current directory", path))
+	}
+}
+
+// checkChmod flags recursively making files writable by everyone
+func (c *checker) checkChmod(call *syntax.CallExpr, args []*syntax.Word) {
+	recursive := false
+	for _, arg := range options(args) {
+		opt := arg.Lit()
+		recursive = recursive || opt == "--recursive" || (!strings.HasPrefix(opt, "--") && strings.Contains(opt, "R"))
+	}
+	if ops := operands(args); recursive && len(ops) > 0 && worldWritable(ops[0].Lit()) {
+		c.add(call, "recursively makes files writable by everyone")
+	}
+}
+
+// path returns the path word names, cleaned and made absolute, and false if
+// it depends on anything but $HOME and $PWD or is relative to an unknown directory
+func (c *checker) path(word *syntax.Word) (string, bool) {
+	text, ok := c.literal(word.Parts)
+	if !ok {
+		return "", false
+	}
+	if text == "~" || strings.HasPrefix(text, "~/") {
+		text = c.home + text[1:]
+	} else if strings.HasPrefix(text, "~") {
+		return "", false // Another user's home
+	}
+	if !filepath.IsAbs(text) {
+		if c.dir == "" {
+			return "", false
+		}
+		text = filepath.Join(c.dir, text)
+	}
+	return filepath.Clean(text), true
+}
+
+// literal returns the text of word parts, expanding $HOME and $PWD
+func (c *checker) literal(parts []syntax.WordPart) (string, bool) {
+	var b strings.Builder
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			b.WriteString(p.Value)
+		case *syntax.SglQuoted:
+			b.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			text, ok := c.literal(p.Parts)
+			if !ok {
+				return "", false
+			}
+			b.WriteString(text)
+		case *syntax.ParamExp:
+			switch {
+			case p.Param == nil || p.Exp != nil || p.Slice != nil || p.Repl != nil || p.Index != nil || p.Length || p.Excl:
+				return "", false
+			case p.Param.Value == "HOME":
+				b.WriteString(c.home)
+			case p.Param.Value == "PWD" && c.dir != "":
+				b.WriteString(c.dir)
+			default:
+				return "", false
+			}
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// wrappers run the command that follows them, with the options that take an argument
+var wrappers = map[string][]string{
+	"sudo":    {"-u", "-g", "-C", "-D", "-h", "-p", "-U", "-r", "-t"},
+	"doas":    {"-u", "-C"},
+	"env":     {"-u", "-C", "-S"},
+	"nice":    {"-n"},
+	"nohup":   nil,
+	"time":    nil,
+	"command": nil,
+	"exec":    nil,
+	"busybox": nil,
+	"xargs":   {"-a", "-d", "-E", "-I", "-L", "-n", "-P", "-s"},
+}
+
+// unwrap skips wrappers such as sudo and their options, returning the
+// command that really runs and its arguments. fromStdin is true when xargs
+// adds arguments read from standard input.
+func unwrap(words []*syntax.Word) (name *syntax.Word, args []*syntax.Word, fromStdin bool) {
+	for len(words) > 0 {
+		withArg, ok := wrappers[filepath.Base(words[0].Lit())]
+		if !ok {
+			return words[0], words[1:], fromStdin
+		}
+		fromStdin = fromStdin || filepath.Base(words[0].Lit()) == "xargs"
+
+		words = words[1:]
+		for len(words) > 0 {
+			opt := words[0].Lit()
+			if opt == "--" {
+				words = words[1:]
+				break
+			}
+			if !strings.HasPrefix(opt, "-") && !strings.Contains(opt, "=") {
+				break
+			}
+			words = words[1:]
+			for _, o := range withArg {
+				if opt == o && len(words) > 0 {
+					words = words[1:]
+				}
+			}
+		}
+	}
+	return nil, nil, fromStdin
+}
+
+// isFindExpression reports whether a find argument starts the expression
+// that follows the starting points
+func isFindExpression(arg string) bool {
+	return (strings.HasPrefix(arg, "-") && arg != "-") || arg == "(" || arg == `\(` || arg == "!" || arg == `\!`
+}
+
+// findDeletes reports whether a find expression deletes what it finds, with
+// -delete or by running rm through -exec and the like
+func findDeletes(expr []*syntax.Word) bool {
+	for i, arg := range expr {
+		switch arg.Lit() {
+		case "-delete":
+			return true
+		case "-exec", "-execdir", "-ok", "-okdir":
+			if name, _, _ := unwrap(expr[i+1:]); name != nil && filepath.Base(name.Lit()) == "rm" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// options returns the options among args, up to "--"
+func options(args []*syntax.Word) []*syntax.Word {
+	var opts []*syntax.Word
+	for _, arg := range args {
+		lit := arg.Lit()
+		if lit == "--" {
+			break
+		}
+		if strings.HasPrefix(lit, "-") && lit != "-" {
+			opts = append(opts, arg)
+		}
+	}
+	return opts
+}
+
+// operands returns the arguments that aren't options
+func operands(args []*syntax.Word) []*syntax.Word {
+	var ops []*syntax.Word
+	afterDashes := false
+	for _, arg := range args {
+		lit := arg.Lit()
+		switch {
+		case afterDashes:
+			ops = append(ops, arg)
+		case lit == "--":
+			afterDashes = true
+		case strings.HasPrefix(lit, "-") && lit != "-":
+		default:
+			ops = append(ops, arg)
+		}
+	}
+	return ops
+}
+
+// diskTools format, partition or wipe disks
+var diskTools = map[string]bool{
+	"mke2fs": true, "mkswap": true, "wipefs": true,
+	"fdisk": true, "sfdisk": true, "gdisk": true, "sgdisk": true, "parted": true,
+}
+
+// shells run the script they are given
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+	"python": true, "python3": true, "perl": true, "ruby": true, "node": true,
+}
+
+// downloaders fetch URLs
+var downloaders = map[string]bool{"curl": true, "wget": true, "fetch": true}
+
+// downloads reports whether node runs a downloader anywhere in it
+func downloads(node syntax.Node) bool {
+	found := false
+	syntax.Walk(node, func(n syntax.Node) bool {
+		if call, ok := n.(*syntax.CallExpr); ok {
+			if name, _, _ := unwrap(call.Args); name != nil && downloaders[filepath.Base(name.Lit())] {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// isInterpreter reports whether stmt runs a shell or script interpreter
+func isInterpreter(stmt *syntax.Stmt) bool {
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok {
+		return false
+	}
+	name, _, _ := unwrap(call.Args)
+	return name != nil && shells[filepath.Base(name.Lit())]
+}
+
+// isForcePush reports whether git args push with --force or a +refspec
+func isForcePush(args []*syntax.Word) bool {
+	// Skip global options such as -C dir before the subcommand
+	for len(args) > 0 && strings.HasPrefix(args[0].Lit(), "-") {
+		if opt := args[0].Lit(); (opt == "-C" || opt == "-c") && len(args) > 1 {
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 || args[0].Lit() != "push" {
+		return false
+	}
+
+	for _, arg := range args[1:] {
+		lit := arg.Lit()
+		switch {
+		case lit == "--force" || strings.HasPrefix(lit, "--force-with-lease") || lit == "--mirror":
+			return true
+		case strings.HasPrefix(lit, "-") && !strings.HasPrefix(lit, "--") && strings.Contains(lit, "f"):
+			return true
+		case strings.HasPrefix(lit, "+"):
+			return true
+		}
+	}
+	return false
+}
+
+// hasInPlace reports whether sed or perl args edit files in place
+func hasInPlace(args []*syntax.Word) bool {
+	for _, arg := range options(args) {
+		lit := arg.Lit()
+		if lit == "--in-place" || strings.HasPrefix(lit, "--in-place=") || (!strings.HasPrefix(lit, "--") && strings.Contains(lit, "i")) {
+			return true
+		}
+	}
+	return false
+}
+
+// worldWritable reports whether a chmod mode lets everyone write, e.g. 777 or a+w
+func worldWritable(mode string) bool {
+	if mode == "" {
+		return false
+	}
+	if strings.Trim(mode, "01234567") == "" {
+		return (mode[len(mode)-1]-'0')&2 != 0
+	}
+	for _, clause := range strings.Split(mode, ",") {
+		perms := strings.TrimLeft(clause, "ugoa")
+		who := clause[:len(clause)-len(perms)]
+		if strings.ContainsAny(who, "oa") && (strings.HasPrefix(perms, "+") || strings.HasPrefix(perms, "=")) && strings.Contains(perms, "w") {
+			return true
+		}
+	}
+	return false
+}
+
+// isDevice reports whether path is a disk or similar device that dd would overwrite
+func isDevice(path string) bool {
+	switch path {
+	case "/dev/null", "/dev/zero", "/dev/stdout", "/dev/stderr", "/dev/tty":
+		return false
+	}
+	return strings.HasPrefix(path, "/dev/")
+}
+
+// inEtc reports whether path is in /etc, which is /private/etc on macOS
+func inEtc(path string) bool {
+	return within(path, "/etc") || within(path, "/private/etc")
+}
+
+// within reports whether path is inside dir
+func within(path, dir string) bool {
+	if dir == "/" {
+		return path != "/"
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
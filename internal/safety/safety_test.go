@@ -0,0 +1,116 @@
+package safety
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		command string
+		want    string // Substring of the reason of the only danger; empty for safe commands
+	}{
+		// Safe commands
+		{command: "ls -la", want: ""},
+		{command: "rm -rf build", want: ""},
+		{command: "rm -rf ./node_modules dist/*", want: ""},
+		{command: "rm notes.txt ~/old.txt", want: ""},
+		{command: "find . -name '*.log' | xargs rm", want: ""},
+		{command: "curl -fsSL https://example.com/data.json | jq .", want: ""},
+		{command: "chmod -R 755 public", want: ""},
+		{command: "chmod 777 upload.sh", want: ""},
+		{command: "git push origin main", want: ""},
+		{command: "cat /etc/hosts > hosts.bak", want: ""},
+		{command: "dd if=/dev/zero of=disk.img bs=1M count=100", want: ""},
+		{command: "echo done > /dev/null", want: ""},
+
+		// Recursive deletes outside the current directory
+		{command: "rm -rf ~", want: "home directory"},
+		{command: "rm -rf \"$HOME\"", want: "home directory"},
+		{command: "sudo rm -rf /", want: "whole filesystem"},
+		{command: "rm -r -f /var/lib/docker", want: "/var/lib/docker, outside the current directory"},
+		{command: "rm -rf ../other", want: "/src/other, outside the current directory"},
+		{command: "rm -rf .", want: "current directory"},
+		{command: "rm -rf $BUILD_DIR/", want: "only known when it runs"},
+		{command: "find /tmp -type d | xargs rm -rf", want: "read from another command"},
+		{command: "ls && echo $(rm -rf /tmp/cache)", want: "/tmp/cache"},
+		{command: "busybox rm -rf /", want: "whole filesystem"},
+		{command: "find . -name '*.tmp' -delete", want: ""},
+		{command: "find -name '*.tmp' -delete", want: ""},
+		{command: "find build -type f -exec rm {} +", want: ""},
+		{command: "find / -name x -exec ls {} \\;", want: ""},
+		{command: "find / -name '*.log' -delete", want: "whole filesystem"},
+		{command: "find ~ -exec rm -rf {} +", want: "home directory"},
+		{command: "find -L /var/log -mtime +7 -execdir sudo rm {} \\;", want: "/var/log, outside the current directory"},
+
+		// Deletes after changing directory
+		{command: "cd build && rm -rf *", want: ""},
+		{command: "(cd /tmp && ls); rm -rf cache", want: ""},
+		{command: "cd / && rm -rf *", want: "whole filesystem"},
+		{command: "cd ~ && rm -rf *", want: "home directory"},
+		{command: "cd; rm -rf *", want: "home directory"},
+		{command: "cd .. && rm -rf app", want: "current directory"},
+		{command: "pushd /var/log && rm -rf old", want: "/var/log/old, outside the current directory"},
+		{command: "(cd /opt && rm -rf data)", want: "/opt/data, outside the current directory"},
+		{command: "cd \"$(mktemp -d)\" && rm -rf tmp", want: "only known when it runs"},
+		{command: "cd - && rm -rf dist", want: "only known when it runs"},
+		{command: "if [ -d x ]; then cd x; fi; rm -rf out", want: "only known when it runs"},
+		{command: "cd $PROJECT && rm -rf build", want: "only known when it runs"},
+
+		// Disks
+		{command: "sudo dd if=ubuntu.iso of=/dev/sdb bs=4M", want: "overwrites the device /dev/sdb"},
+		{command: "mkfs.ext4 /dev/sdb1", want: "formats"},
+		{command: "diskutil eraseDisk APFS Backup disk4", want: "erases"},
+
+		// Downloaded scripts
+		{command: "curl -fsSL https://get.example.com | sh", want: "downloaded"},
+		{command: "wget -qO- https://example.com/install | sudo bash -s -- --yes", want: "downloaded"},
+		{command: "bash <(curl -s https://example.com/setup.sh)", want: "downloaded"},
+		{command: "sh -c \"$(curl -fsSL https://example.com/install.sh)\"", want: "downloaded"},
+
+		// Permissions
+		{command: "chmod -R 777 /var/www", want: "writable by everyone"},
+		{command: "sudo chmod -R a+rwx .", want: "writable by everyone"},
+
+		// Force pushes
+		{command: "git push --force origin main", want: "force pushes"},
+		{command: "git -C repo push -uf origin feature", want: "force pushes"},
+		{command: "git push origin +main", want: "force pushes"},
+
+		// Writes to /etc
+		{command: "echo '127.0.0.1 dev.local' | sudo tee -a /etc/hosts", want: "/etc/hosts"},
+		{command: "echo 'nameserver 1.1.1.1' > /etc/resolv.conf", want: "/etc/resolv.conf"},
+		{command: "sudo sed -i 's/^#Port 22/Port 2222/' /etc/ssh/sshd_config", want: "/etc/ssh/sshd_config"},
+		{command: "sudo cp nginx.conf /etc/nginx/nginx.conf", want: "/etc/nginx/nginx.conf"},
+
+		// Unparsable commands can't be vouched for
+		{command: "echo 'unterminated", want: "doesn't parse"},
+	}
+
+	for _, tt := range tests {
+		dangers := Check(tt.command, "/src/app", "/home/ana")
+		if tt.want == "" {
+			if len(dangers) != 0 {
+				t.Errorf("Check(%q) = %+v, want no dangers", tt.command, dangers)
+			}
+			continue
+		}
+		if len(dangers) != 1 || !strings.Contains(dangers[0].Reason, tt.want) {
+			t.Errorf("Check(%q) = %+v, want one danger about %q", tt.command, dangers, tt.want)
+		}
+	}
+}
+
+func TestCheckReportsEachPart(t *testing.T) {
+	t.Parallel()
+
+	dangers := Check("cd /tmp && rm -rf ~/projects; curl https://x.sh | sh", "/src/app", "/home/ana")
+	if len(dangers) != 2 {
+		t.Fatalf("Check = %+v, want two dangers", dangers)
+	}
+	if dangers[0].Command != "rm -rf ~/projects" || dangers[1].Command != "curl https://x.sh | sh" {
+		t.Errorf("dangerous parts = %q and %q, want the delete and the pipe", dangers[0].Command, dangers[1].Command)
+	}
+}
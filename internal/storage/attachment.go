@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Attachment is a file sent with a message, such as an image for a vision model
+type Attachment struct {
+	ID        string    `json:"id" db:"id"`
+	MessageID string    `json:"message_id" db:"message_id"`
+	Filename  string    `json:"filename" db:"filename"`
+	MimeType  string    `json:"mime_type" db:"mime_type"`
+	Data      []byte    `json:"-" db:"data"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// GetAttachment retrieves an attachment by ID, including its data
+func (s *Store) GetAttachment(id string) (*Attachment, error) {
+	attachment := &Attachment{}
+	err := s.db.Get(attachment, "SELECT * FROM attachments WHERE id = ?", id)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Not found
+	}
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// getChatAttachments retrieves the attachments of every message in a chat, keyed by message ID
+func (s *Store) getChatAttachments(chatID string) (map[string][]*Attachment, error) {
+	attachments := []*Attachment{}
+	err := s.db.Select(&attachments, `
+		SELECT a.* FROM attachments a
+		JOIN messages m ON m.id = a.message_id
+		WHERE m.chat_id = ?
+		ORDER BY a.created_at ASC
+	`, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	byMessage := make(map[string][]*Attachment)
+	for _, a := range attachments {
+		byMessage[a.MessageID] = append(byMessage[a.MessageID], a)
+	}
+	return byMessage, nil
+}
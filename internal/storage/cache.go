@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+// CachedCommand is a generated command kept to answer the same request again
+type CachedCommand struct {
+	Key       string    `json:"key" db:"key"`
+	Model     string    `json:"model" db:"model"`
+	Prompt    string    `json:"prompt" db:"prompt"`
+	Response  string    `json:"response" db:"response"` // The command as JSON
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CommandCacheKey identifies a request by the parts that shape its answer,
+// such as the backend, model, options, system prompt and prompt
+func CommandCacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GetCachedCommand retrieves a cached command no older than maxAge
+func (s *Store) GetCachedCommand(key string, maxAge time.Duration) (*CachedCommand, error) {
+	cached := &CachedCommand{}
+	err := s.db.Get(cached, "SELECT * FROM command_cache WHERE key = ?", key)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Not found
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(cached.CreatedAt) > maxAge {
+		return nil, nil // Expired
+	}
+	return cached, nil
+}
+
+// PutCachedCommand stores a command, replacing any entry with the same key,
+// and drops entries older than maxAge
+func (s *Store) PutCachedCommand(cached *CachedCommand, maxAge time.Duration) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO command_cache (key, model, prompt, response, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, cached.Key, cached.Model, cached.Prompt, cached.Response, cached.CreatedAt.UTC())
+	if err != nil {
+		return err
+	}
+
+	// Timestamps are stored in UTC, so they compare as text
+	_, err = tx.Exec("DELETE FROM command_cache WHERE created_at < ?", time.Now().Add(-maxAge).UTC())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
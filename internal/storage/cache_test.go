@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// openTestStore opens a store in a temporary data directory
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	store, err := OpenStore(io.Discard)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestCommandCache(t *testing.T) {
+	store := openTestStore(t)
+
+	key := CommandCacheKey("ollama", "", "llama3.2", "{}", "template", "list files", "linux")
+	cached, err := store.GetCachedCommand(key, time.Hour)
+	if err != nil || cached != nil {
+		t.Fatalf("GetCachedCommand on an empty cache = %+v, %v, want nil, nil", cached, err)
+	}
+
+	put := func(response string, createdAt time.Time) {
+		t.Helper()
+		err := store.PutCachedCommand(&CachedCommand{
+			Key: key, Model: "llama3.2", Prompt: "list files", Response: response, CreatedAt: createdAt,
+		}, time.Hour)
+		if err != nil {
+			t.Fatalf("PutCachedCommand: %v", err)
+		}
+	}
+
+	put(`{"command":"ls"}`, time.Now())
+	put(`{"command":"ls -la"}`, time.Now())
+	cached, err = store.GetCachedCommand(key, time.Hour)
+	if err != nil {
+		t.Fatalf("GetCachedCommand: %v", err)
+	}
+	if cached == nil || cached.Response != `{"command":"ls -la"}` || cached.Model != "llama3.2" {
+		t.Fatalf("GetCachedCommand = %+v, want the latest entry", cached)
+	}
+
+	if other := CommandCacheKey("ollama", "", "llama3.2", `{"temperature":0.5}`, "template", "list files", "linux"); other == key {
+		t.Error("keys for different options are equal")
+	}
+	if CommandCacheKey("a", "bc") == CommandCacheKey("ab", "c") {
+		t.Error("keys for differently split parts are equal")
+	}
+}
+
+func TestCommandCacheExpires(t *testing.T) {
+	store := openTestStore(t)
+
+	old := &CachedCommand{Key: "old", Response: `{"command":"ls"}`, CreatedAt: time.Now().Add(-2 * time.Hour)}
+	if err := store.PutCachedCommand(old, 3*time.Hour); err != nil {
+		t.Fatalf("PutCachedCommand: %v", err)
+	}
+
+	if cached, err := store.GetCachedCommand("old", 3*time.Hour); err != nil || cached == nil {
+		t.Fatalf("GetCachedCommand within the TTL = %+v, %v, want the entry", cached, err)
+	}
+	if cached, err := store.GetCachedCommand("old", time.Hour); err != nil || cached != nil {
+		t.Fatalf("GetCachedCommand past the TTL = %+v, %v, want nil, nil", cached, err)
+	}
+
+	// Storing another command drops the entries older than the TTL
+	fresh := &CachedCommand{Key: "fresh", Response: `{"command":"pwd"}`, CreatedAt: time.Now()}
+	if err := store.PutCachedCommand(fresh, time.Hour); err != nil {
+		t.Fatalf("PutCachedCommand: %v", err)
+	}
+	var keys []string
+	if err := store.DB().Select(&keys, "SELECT key FROM command_cache"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "fresh" {
+		t.Errorf("cached keys = %v, want only the fresh one", keys)
+	}
+}
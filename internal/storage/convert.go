@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/misrab/clai/internal/ai"
+)
+
+// ToAIMessages converts stored chat messages into the provider's message format,
+// passing image attachments along for vision models. Reasoning isn't sent back.
+func ToAIMessages(messages []*Message) []ai.Message {
+	result := make([]ai.Message, 0, len(messages))
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleToolCall:
+			var calls []ai.ToolCall
+			if err := json.Unmarshal([]byte(msg.Content), &calls); err != nil {
+				continue // Unreadable calls are left out rather than sent garbled
+			}
+			result = append(result, ai.Message{Role: ai.RoleAssistant, ToolCalls: calls})
+		case RoleToolResult:
+			result = append(result, ai.Message{Role: ai.RoleTool, Content: msg.Content, ToolName: msg.ToolName})
+		default:
+			aiMsg := ai.Message{Role: msg.Role, Content: msg.Content}
+			for _, a := range msg.Attachments {
+				if strings.HasPrefix(a.MimeType, "image/") {
+					aiMsg.Images = append(aiMsg.Images, a.Data)
+				}
+			}
+			result = append(result, aiMsg)
+		}
+	}
+	return result
+}
+
+// FromAIMessage converts a message of a conversation into the rows to store.
+// An assistant message that calls tools becomes its text, if any, followed by
+// a tool_call row.
+func FromAIMessage(chatID string, msg ai.Message) ([]*Message, error) {
+	now := time.Now()
+	newMessage := func(role, content string) *Message {
+		return &Message{ID: NewID(), ChatID: chatID, Role: role, Content: content, CreatedAt: now}
+	}
+
+	switch msg.Role {
+	case ai.RoleUser:
+		stored := newMessage(RoleUser, msg.Content)
+		for i, image := range msg.Images {
+			stored.Attachments = append(stored.Attachments, &Attachment{
+				ID:        NewID(),
+				Filename:  fmt.Sprintf("image-%d", i+1),
+				MimeType:  http.DetectContentType(image),
+				Data:      image,
+				CreatedAt: now,
+			})
+		}
+		return []*Message{stored}, nil
+	case ai.RoleAssistant:
+		var result []*Message
+		if msg.Content != "" || msg.Thinking != "" || len(msg.ToolCalls) == 0 {
+			stored := newMessage(RoleAssistant, msg.Content)
+			stored.Thinking = msg.Thinking
+			result = append(result, stored)
+		}
+		if len(msg.ToolCalls) > 0 {
+			calls, err := json.Marshal(msg.ToolCalls)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, newMessage(RoleToolCall, string(calls)))
+		}
+		return result, nil
+	case ai.RoleTool:
+		stored := newMessage(RoleToolResult, msg.Content)
+		stored.ToolName = msg.ToolName
+		return []*Message{stored}, nil
+	default:
+		return nil, fmt.Errorf("cannot store %s message", msg.Role)
+	}
+}
+
+// StoredCount returns the number of rows FromAIMessage stores for messages,
+// which is the number of messages ToAIMessages reads back for them
+func StoredCount(messages []ai.Message) int {
+	count := 0
+	for _, msg := range messages {
+		if msg.Role == ai.RoleAssistant && len(msg.ToolCalls) > 0 && (msg.Content != "" || msg.Thinking != "") {
+			count++ // The text is stored apart from the calls
+		}
+		count++
+	}
+	return count
+}
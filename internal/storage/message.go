@@ -4,16 +4,28 @@ import (
 	"time"
 )
 
+// Message roles stored in the messages table
+const (
+	RoleUser       = "user"
+	RoleAssistant  = "assistant"
+	RoleToolCall   = "tool_call"   // Content is the JSON list of calls the assistant asked for
+	RoleToolResult = "tool_result" // Content is the output of the tool named in ToolName
+)
+
 // Message represents a single message in a chat
 type Message struct {
 	ID        string    `json:"id" db:"id"`
 	ChatID    string    `json:"chat_id" db:"chat_id"`
-	Role      string    `json:"role" db:"role"` // "user" or "assistant"
+	Role      string    `json:"role" db:"role"` // One of the roles above
 	Content   string    `json:"content" db:"content"`
+	ToolName  string    `json:"tool_name,omitempty" db:"tool_name"`
+	Thinking  string    `json:"thinking,omitempty" db:"thinking"` // Reasoning of a thinking model, for assistant messages
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	Attachments []*Attachment `json:"attachments,omitempty" db:"-"`
 }
 
-// CreateMessage creates a new message in the database
+// CreateMessage creates a new message and its attachments in the database
 func (s *Store) CreateMessage(msg *Message) error {
 	tx, err := s.db.Beginx()
 	if err != nil {
@@ -23,13 +35,25 @@ func (s *Store) CreateMessage(msg *Message) error {
 
 	// Insert message
 	_, err = tx.Exec(`
-		INSERT INTO messages (id, chat_id, role, content, created_at)
-		VALUES (?, ?, ?, ?, ?)
-	`, msg.ID, msg.ChatID, msg.Role, msg.Content, msg.CreatedAt)
+		INSERT INTO messages (id, chat_id, role, content, tool_name, thinking, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, msg.ID, msg.ChatID, msg.Role, msg.Content, msg.ToolName, msg.Thinking, msg.CreatedAt)
 	if err != nil {
 		return err
 	}
 
+	// Insert attachments
+	for _, a := range msg.Attachments {
+		a.MessageID = msg.ID
+		_, err = tx.Exec(`
+			INSERT INTO attachments (id, message_id, filename, mime_type, data, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, a.ID, a.MessageID, a.Filename, a.MimeType, a.Data, a.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Update chat's updated_at timestamp
 	_, err = tx.Exec(`
 		UPDATE chats SET updated_at = ? WHERE id = ?
@@ -41,7 +65,7 @@ func (s *Store) CreateMessage(msg *Message) error {
 	return tx.Commit()
 }
 
-// GetMessages retrieves all messages for a chat, ordered by creation time
+// GetMessages retrieves all messages for a chat with their attachments, ordered by creation time
 func (s *Store) GetMessages(chatID string) ([]*Message, error) {
 	messages := []*Message{}
 	err := s.db.Select(&messages, `
@@ -52,6 +76,14 @@ func (s *Store) GetMessages(chatID string) ([]*Message, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	attachments, err := s.getChatAttachments(chatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range messages {
+		msg.Attachments = attachments[msg.ID]
+	}
 	return messages, nil
 }
 
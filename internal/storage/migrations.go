@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"embed"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
 )
@@ -12,7 +13,7 @@ import (
 var migrationsFS embed.FS
 
 // runMigrations applies all pending database migrations
-func runMigrations(db *sql.DB) error {
+func runMigrations(db *sql.DB, log io.Writer) error {
 	// Create migrations tracking table
 	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS schema_migrations (
@@ -54,7 +55,7 @@ func runMigrations(db *sql.DB) error {
 			continue // Already applied
 		}
 
-		fmt.Printf("Applying migration %d: %s\n", version, filename)
+		fmt.Fprintf(log, "Applying migration %d: %s\n", version, filename)
 
 		sqlContent, err := migrationsFS.ReadFile("migrations/" + filename)
 		if err != nil {
@@ -85,11 +86,11 @@ func runMigrations(db *sql.DB) error {
 			return fmt.Errorf("commit migration %s: %w", filename, err)
 		}
 
-		fmt.Printf("✓ Migration %d applied successfully\n", version)
+		fmt.Fprintf(log, "✓ Migration %d applied successfully\n", version)
 	}
 
 	if len(migrationFiles) == currentVersion {
-		fmt.Println("Database is up to date")
+		fmt.Fprintln(log, "Database is up to date")
 	}
 
 	return nil
@@ -0,0 +1,27 @@
+-- Allow tool calls and their results in the messages table.
+-- SQLite can't alter a CHECK constraint, so the table is rebuilt. Dropping it
+-- cascades to attachments, which are copied aside and restored.
+CREATE TEMP TABLE attachments_backup AS SELECT * FROM attachments;
+
+CREATE TABLE messages_new (
+    id TEXT PRIMARY KEY,
+    chat_id TEXT NOT NULL,
+    role TEXT NOT NULL CHECK(role IN ('user', 'assistant', 'tool_call', 'tool_result')),
+    content TEXT NOT NULL,
+    tool_name TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (chat_id) REFERENCES chats(id) ON DELETE CASCADE
+);
+
+INSERT INTO messages_new (id, chat_id, role, content, created_at)
+SELECT id, chat_id, role, content, created_at FROM messages;
+
+DROP TABLE messages;
+ALTER TABLE messages_new RENAME TO messages;
+
+INSERT INTO attachments SELECT * FROM attachments_backup;
+DROP TABLE attachments_backup;
+
+-- Recreate indexes
+CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id);
+CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at);
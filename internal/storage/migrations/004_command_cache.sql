@@ -0,0 +1,11 @@
+-- Create cache of generated commands
+CREATE TABLE IF NOT EXISTS command_cache (
+    key TEXT PRIMARY KEY,
+    model TEXT NOT NULL,
+    prompt TEXT NOT NULL,
+    response TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_command_cache_created_at ON command_cache(created_at);
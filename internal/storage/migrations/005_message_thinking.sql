@@ -0,0 +1,2 @@
+-- Keep the reasoning of thinking models apart from the answer
+ALTER TABLE messages ADD COLUMN thinking TEXT NOT NULL DEFAULT '';
@@ -1,7 +1,11 @@
 package storage
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
@@ -14,12 +18,18 @@ type Store struct {
 
 // NewStore creates a new Store instance and initializes the database
 func NewStore() (*Store, error) {
+	return OpenStore(os.Stdout)
+}
+
+// OpenStore is NewStore with progress messages, such as applied migrations,
+// written to log instead of stdout
+func OpenStore(log io.Writer) (*Store, error) {
 	dbPath, err := GetDBPath()
 	if err != nil {
 		return nil, fmt.Errorf("get db path: %w", err)
 	}
 
-	fmt.Printf("Initializing database at: %s\n", dbPath)
+	fmt.Fprintf(log, "Initializing database at: %s\n", dbPath)
 
 	// Open database with pragmas for better performance and safety
 	// _foreign_keys=on enables foreign key constraints
@@ -41,7 +51,7 @@ func NewStore() (*Store, error) {
 	db.SetMaxIdleConns(1)
 
 	// Run migrations
-	if err := runMigrations(db.DB); err != nil {
+	if err := runMigrations(db.DB, log); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("run migrations: %w", err)
 	}
@@ -61,3 +71,10 @@ func (s *Store) Close() error {
 func (s *Store) DB() *sqlx.DB {
 	return s.db
 }
+
+// NewID generates a random ID for a chat, message or attachment
+func NewID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ChatSummary is the rolling summary of the first messages of a long chat,
+// sent in their place so the conversation fits the model's context window
+type ChatSummary struct {
+	ChatID       string    `json:"chat_id" db:"chat_id"`
+	Summary      string    `json:"summary" db:"summary"`             // Empty when old messages are dropped instead
+	MessageCount int       `json:"message_count" db:"message_count"` // Number of stored messages it replaces
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// GetChatSummary retrieves the summary of a chat, or nil if it has none
+func (s *Store) GetChatSummary(chatID string) (*ChatSummary, error) {
+	summary := &ChatSummary{}
+	err := s.db.Get(summary, "SELECT * FROM chat_summaries WHERE chat_id = ?", chatID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Not found
+	}
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// SaveChatSummary stores the summary of a chat, replacing the previous one
+func (s *Store) SaveChatSummary(summary *ChatSummary) error {
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO chat_summaries (chat_id, summary, message_count, updated_at)
+		VALUES (?, ?, ?, ?)
+	`, summary.ChatID, summary.Summary, summary.MessageCount, summary.UpdatedAt)
+	return err
+}
@@ -1,10 +1,12 @@
 package webui
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/misrab/clai/internal/ai"
@@ -147,14 +149,36 @@ func HandleDeleteChat(store *storage.Store) http.HandlerFunc {
 
 // sendMessageRequest represents the request body for sending a message
 type sendMessageRequest struct {
-	UserMessageID string `json:"userMessageId"`
-	Content       string `json:"content"`
-	Model         string `json:"model,omitempty"`
+	UserMessageID string        `json:"userMessageId"`
+	Content       string        `json:"content"`
+	Model         string        `json:"model,omitempty"`
+	Options       ai.Options    `json:"options,omitempty"`
+	Images        []imageUpload `json:"images,omitempty"`
+}
+
+// imageUpload is an image sent with a message; Data is base64-encoded in JSON
+type imageUpload struct {
+	Filename string `json:"filename"`
+	Data     []byte `json:"data"`
+}
+
+// maxImageSize is the largest image accepted with a message
+const maxImageSize = 20 << 20
+
+// ProviderFactory builds an AI provider for the given model, applying any
+// generation options sent with the request. An empty model selects the
+// default chat model.
+type ProviderFactory func(model string, opts ai.Options) (ai.Provider, error)
+
+// ContextOptions configure how chats are kept within the model's context window
+type ContextOptions struct {
+	MaxTokens int  // Zero uses ai.DefaultContextTokens; a request's num_ctx overrides it
+	Summarize bool // Summarise old turns with the chat's model instead of dropping them
 }
 
 // HandleSendMessage handles POST /api/chats/{id}/send
-// Saves user message, gets AI response from Ollama, and streams or returns the response
-func HandleSendMessage(store *storage.Store) http.HandlerFunc {
+// Saves user message, gets AI response from the provider, and streams or returns the response
+func HandleSendMessage(store *storage.Store, newProvider ProviderFactory, contextOpts ContextOptions) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		chatID := getURLParam(r, "id")
 		if chatID == "" {
@@ -176,13 +200,20 @@ func HandleSendMessage(store *storage.Store) http.HandlerFunc {
 			return
 		}
 
-		// Save user message
+		attachments, err := imageAttachments(req.Images)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Save user message with its attachments
 		userMessage := &storage.Message{
-			ID:        req.UserMessageID,
-			ChatID:    chatID,
-			Role:      "user",
-			Content:   req.Content,
-			CreatedAt: time.Now(),
+			ID:          req.UserMessageID,
+			ChatID:      chatID,
+			Role:        "user",
+			Content:     req.Content,
+			CreatedAt:   time.Now(),
+			Attachments: attachments,
 		}
 
 		if err := store.CreateMessage(userMessage); err != nil {
@@ -190,21 +221,76 @@ func HandleSendMessage(store *storage.Store) http.HandlerFunc {
 			return
 		}
 
-		// Use specified model or default
-		model := req.Model
-		if model == "" {
-			model = "codellama:7b"
+		// Rebuild the conversation so the model sees earlier turns
+		history, err := store.GetMessages(chatID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to load chat history: %v", err))
+			return
+		}
+
+		// An empty model leaves the choice to the provider factory
+		provider, err := newProvider(req.Model, req.Options)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		messages, err := fitContext(r.Context(), store, chatID, storage.ToAIMessages(history), provider, req.Options, contextOpts)
+		if err != nil {
+			respondError(w, aiErrorStatus(err), fmt.Sprintf("Failed to summarize chat history: %v", err))
+			return
 		}
 
 		// Server decides whether to stream (default: always stream for now)
 		if shouldStream(req.Content) {
-			handleStreamingResponse(w, r, chatID, req.Content, model, store)
+			handleStreamingResponse(w, r, chatID, messages, provider, store)
 		} else {
-			handleNonStreamingResponse(w, chatID, req.Content, model, store)
+			handleNonStreamingResponse(w, r, chatID, messages, provider, store)
 		}
 	}
 }
 
+// fitContext returns the messages of a chat to send, resuming from its stored
+// summary and saving the summary again when older messages were folded in
+func fitContext(ctx context.Context, store *storage.Store, chatID string, history []ai.Message,
+	provider ai.Provider, opts ai.Options, contextOpts ContextOptions) ([]ai.Message, error) {
+
+	manager := &ai.ContextManager{MaxTokens: contextOpts.MaxTokens}
+	if opts.NumCtx != nil {
+		manager.MaxTokens = *opts.NumCtx
+	}
+	if contextOpts.Summarize {
+		manager.Summarizer = provider
+	}
+
+	var summary string
+	var summarized int
+	stored, err := store.GetChatSummary(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil {
+		summary, summarized = stored.Summary, stored.MessageCount
+	}
+
+	window, err := manager.Fit(ctx, summary, summarized, history)
+	if err != nil {
+		return nil, err
+	}
+	if window.Summarized != summarized || window.Summary != summary {
+		err := store.SaveChatSummary(&storage.ChatSummary{
+			ChatID:       chatID,
+			Summary:      window.Summary,
+			MessageCount: window.Summarized,
+			UpdatedAt:    time.Now(),
+		})
+		if err != nil {
+			fmt.Printf("Failed to save chat summary: %v\n", err)
+		}
+	}
+	return window.Messages, nil
+}
+
 // shouldStream determines if the response should be streamed
 // For now, always returns true. In the future, can check content type, metadata, etc.
 func shouldStream(content string) bool {
@@ -217,7 +303,7 @@ func shouldStream(content string) bool {
 
 // handleStreamingResponse streams the AI response using SSE
 func handleStreamingResponse(w http.ResponseWriter, r *http.Request,
-	chatID, content, model string, store *storage.Store) {
+	chatID string, messages []ai.Message, provider ai.Provider, store *storage.Store) {
 
 	setupSSE(w)
 
@@ -227,19 +313,24 @@ func handleStreamingResponse(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	client := ai.NewClient(model)
 	assistantID := generateMessageID()
-	var fullResponse string
 
-	// Stream chunks to client and accumulate full response
-	err := client.ChatStream(content, func(chunk string) error {
-		// Check if client disconnected
-		if r.Context().Err() != nil {
-			return fmt.Errorf("client disconnected")
+	// Reasoning of thinking models is streamed as separate events so the UI can fold it
+	ctx := ai.WithThinking(r.Context(), func(chunk string) error {
+		if err := writeSSE(w, map[string]interface{}{
+			"id":       assistantID,
+			"thinking": chunk,
+			"done":     false,
+		}); err != nil {
+			return err
 		}
+		flusher.Flush()
+		return nil
+	})
 
-		fullResponse += chunk
-
+	// Stream chunks to client; the reply holds the full response once done.
+	// The request context is cancelled when the client disconnects, which aborts the stream
+	reply, err := provider.ChatStream(ctx, messages, func(chunk string) error {
 		// Send chunk via SSE
 		if err := writeSSE(w, map[string]interface{}{
 			"id":    assistantID,
@@ -253,9 +344,11 @@ func handleStreamingResponse(w http.ResponseWriter, r *http.Request,
 	})
 
 	if err != nil {
+		// Headers are already sent, so the status travels in the event
 		writeSSE(w, map[string]interface{}{
-			"error": err.Error(),
-			"done":  true,
+			"error":  err.Error(),
+			"status": aiErrorStatus(err),
+			"done":   true,
 		})
 		flusher.Flush()
 		return
@@ -266,7 +359,8 @@ func handleStreamingResponse(w http.ResponseWriter, r *http.Request,
 		ID:        assistantID,
 		ChatID:    chatID,
 		Role:      "assistant",
-		Content:   fullResponse,
+		Content:   reply.Content,
+		Thinking:  reply.Thinking,
 		CreatedAt: time.Now(),
 	}
 
@@ -276,21 +370,22 @@ func handleStreamingResponse(w http.ResponseWriter, r *http.Request,
 
 	// Send final event with full message
 	writeSSE(w, map[string]interface{}{
-		"id":      assistantID,
-		"content": fullResponse,
-		"done":    true,
+		"id":       assistantID,
+		"content":  reply.Content,
+		"thinking": reply.Thinking,
+		"metrics":  metricsJSON(reply.Metrics),
+		"done":     true,
 	})
 	flusher.Flush()
 }
 
 // handleNonStreamingResponse returns the complete AI response at once
-func handleNonStreamingResponse(w http.ResponseWriter,
-	chatID, content, model string, store *storage.Store) {
+func handleNonStreamingResponse(w http.ResponseWriter, r *http.Request,
+	chatID string, messages []ai.Message, provider ai.Provider, store *storage.Store) {
 
-	client := ai.NewClient(model)
-	aiResponse, err := client.Chat(content)
+	reply, err := provider.Chat(r.Context(), messages)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		respondError(w, aiErrorStatus(err), err.Error())
 		return
 	}
 
@@ -299,7 +394,8 @@ func handleNonStreamingResponse(w http.ResponseWriter,
 		ID:        generateMessageID(),
 		ChatID:    chatID,
 		Role:      "assistant",
-		Content:   aiResponse,
+		Content:   reply.Content,
+		Thinking:  reply.Thinking,
 		CreatedAt: time.Now(),
 	}
 
@@ -311,6 +407,65 @@ func handleNonStreamingResponse(w http.ResponseWriter,
 	respondJSON(w, http.StatusCreated, assistantMessage)
 }
 
+// HandleGetAttachment handles GET /api/attachments/{id}, serving the stored file
+func HandleGetAttachment(store *storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := getURLParam(r, "id")
+		if id == "" {
+			respondError(w, http.StatusBadRequest, "Attachment ID is required")
+			return
+		}
+
+		attachment, err := store.GetAttachment(id)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get attachment: %v", err))
+			return
+		}
+
+		if attachment == nil {
+			respondError(w, http.StatusNotFound, "Attachment not found")
+			return
+		}
+
+		w.Header().Set("Content-Type", attachment.MimeType)
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+		w.WriteHeader(http.StatusOK)
+		w.Write(attachment.Data)
+	}
+}
+
+// imageAttachments validates uploaded images and turns them into attachments
+func imageAttachments(images []imageUpload) ([]*storage.Attachment, error) {
+	attachments := make([]*storage.Attachment, 0, len(images))
+	for i, image := range images {
+		name := image.Filename
+		if name == "" {
+			name = fmt.Sprintf("image-%d", i+1)
+		}
+
+		if len(image.Data) == 0 {
+			return nil, fmt.Errorf("image %s is empty", name)
+		}
+		if len(image.Data) > maxImageSize {
+			return nil, fmt.Errorf("image %s is larger than %d MB", name, maxImageSize>>20)
+		}
+
+		mimeType := http.DetectContentType(image.Data)
+		if !strings.HasPrefix(mimeType, "image/") {
+			return nil, fmt.Errorf("%s is not an image (%s)", name, mimeType)
+		}
+
+		attachments = append(attachments, &storage.Attachment{
+			ID:        generateMessageID(),
+			Filename:  name,
+			MimeType:  mimeType,
+			Data:      image.Data,
+			CreatedAt: time.Now(),
+		})
+	}
+	return attachments, nil
+}
+
 // generateMessageID generates a random message ID
 func generateMessageID() string {
 	b := make([]byte, 16)
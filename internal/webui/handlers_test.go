@@ -0,0 +1,240 @@
+package webui
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/ai/aitest"
+	"github.com/misrab/clai/internal/storage"
+)
+
+// newTestRouter serves the send and attachment endpoints backed by a fake
+// Ollama server and a fresh database holding one chat, "c1"
+func newTestRouter(t *testing.T) (*aitest.Server, *storage.Store, http.Handler) {
+	t.Helper()
+
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	store, err := storage.OpenStore(io.Discard)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	now := time.Now()
+	if err := store.CreateChat(&storage.Chat{ID: "c1", Title: "Test", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("create chat: %v", err)
+	}
+
+	srv := aitest.NewServer(t)
+	newProvider := func(model string, opts ai.Options) (ai.Provider, error) {
+		cfg := srv.Config()
+		cfg.Options = opts
+		return ai.NewProvider(cfg)
+	}
+
+	r := chi.NewRouter()
+	r.Post("/api/chats/{id}/send", HandleSendMessage(store, newProvider, ContextOptions{Summarize: true}))
+	r.Get("/api/attachments/{id}", HandleGetAttachment(store))
+	return srv, store, r
+}
+
+// sendMessage posts body to the send endpoint and returns the SSE events
+func sendMessage(t *testing.T, h http.Handler, body string) []map[string]interface{} {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chats/c1/send", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var events []map[string]interface{}
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("decode event %q: %v", data, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestHandleSendMessageStreams(t *testing.T) {
+	srv, store, h := newTestRouter(t)
+	srv.Enqueue(aitest.Response{Chunks: []string{"Hello", " there"}})
+
+	events := sendMessage(t, h, `{"userMessageId":"u1","content":"hi"}`)
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 2 chunks and done: %v", len(events), events)
+	}
+	if events[0]["chunk"] != "Hello" || events[1]["chunk"] != " there" {
+		t.Errorf("chunks = %v, %v, want the scripted ones", events[0]["chunk"], events[1]["chunk"])
+	}
+	if done := events[2]; done["done"] != true || done["content"] != "Hello there" || done["metrics"] == nil {
+		t.Errorf("final event = %v, want the full reply with metrics", done)
+	}
+
+	messages, err := store.GetMessages("c1")
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Content != "hi" || messages[1].Content != "Hello there" {
+		t.Errorf("stored messages = %+v, want the prompt and the reply", messages)
+	}
+}
+
+func TestHandleSendMessageErrors(t *testing.T) {
+	srv, store, h := newTestRouter(t)
+	srv.Enqueue(aitest.Response{Chunks: []string{"Hel"}, StreamError: "model runner crashed"})
+
+	events := sendMessage(t, h, `{"userMessageId":"u1","content":"hi"}`)
+	last := events[len(events)-1]
+	if last["error"] == nil || last["status"] != float64(http.StatusBadGateway) {
+		t.Errorf("final event = %v, want an error with status 502", last)
+	}
+
+	// Only the user's message is kept
+	messages, err := store.GetMessages("c1")
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Errorf("stored %d messages, want 1", len(messages))
+	}
+}
+
+func TestHandleSendMessageSeparatesThinking(t *testing.T) {
+	srv, store, h := newTestRouter(t)
+	srv.Enqueue(aitest.Response{Chunks: []string{"<think>The user", " says hi</think>\n", "Hello!"}})
+
+	events := sendMessage(t, h, `{"userMessageId":"u1","content":"hi"}`)
+	var thinking, content string
+	for _, event := range events[:len(events)-1] {
+		if s, ok := event["thinking"].(string); ok {
+			thinking += s
+		}
+		if s, ok := event["chunk"].(string); ok {
+			content += s
+		}
+	}
+	if thinking != "The user says hi" || content != "Hello!" {
+		t.Errorf("streamed thinking %q and content %q, want them apart", thinking, content)
+	}
+	if done := events[len(events)-1]; done["content"] != "Hello!" || done["thinking"] != "The user says hi" {
+		t.Errorf("final event = %v, want content and thinking apart", done)
+	}
+
+	messages, err := store.GetMessages("c1")
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 2 || messages[1].Content != "Hello!" || messages[1].Thinking != "The user says hi" {
+		t.Errorf("stored messages = %+v, want the reply with its thinking apart", messages)
+	}
+}
+
+func TestHandleSendMessageWithImage(t *testing.T) {
+	srv, store, h := newTestRouter(t)
+
+	// The PNG signature and header chunk are enough for the type to be detected
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+	body, _ := json.Marshal(map[string]interface{}{
+		"userMessageId": "u1",
+		"content":       "what is this?",
+		"images":        []map[string]interface{}{{"filename": "dot.png", "data": png}},
+	})
+	events := sendMessage(t, h, string(body))
+	if done := events[len(events)-1]; done["done"] != true {
+		t.Fatalf("final event = %v, want the reply", done)
+	}
+
+	// The image reaches the model with the prompt
+	sent := srv.Requests()[0].Messages
+	if last := sent[len(sent)-1]; last.Content != "what is this?" || len(last.Images) != 1 || string(last.Images[0]) != string(png) {
+		t.Errorf("last message sent = %+v, want the prompt with the image", last)
+	}
+
+	messages, err := store.GetMessages("c1")
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 2 || len(messages[0].Attachments) != 1 {
+		t.Fatalf("stored messages = %+v, want the prompt with one attachment", messages)
+	}
+	attachment := messages[0].Attachments[0]
+
+	req := httptest.NewRequest(http.MethodGet, "/api/attachments/"+attachment.ID, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || rec.Body.String() != string(png) {
+		t.Errorf("GET attachment = %d %s %q, want the stored PNG", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/attachments/missing", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET missing attachment = %d, want 404", rec.Code)
+	}
+
+	// Anything but an image is refused
+	body, _ = json.Marshal(map[string]interface{}{
+		"userMessageId": "u2",
+		"content":       "and this?",
+		"images":        []map[string]interface{}{{"filename": "notes.txt", "data": []byte("plain text")}},
+	})
+	req = httptest.NewRequest(http.MethodPost, "/api/chats/c1/send", strings.NewReader(string(body)))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || len(srv.Requests()) != 1 {
+		t.Errorf("sending text as an image = %d after %d requests, want 400 without asking the model", rec.Code, len(srv.Requests()))
+	}
+}
+
+func TestHandleSendMessageSummarizesLongChats(t *testing.T) {
+	srv, store, h := newTestRouter(t)
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 12; i++ {
+		role := storage.RoleUser
+		if i%2 == 1 {
+			role = storage.RoleAssistant
+		}
+		msg := &storage.Message{ID: storage.NewID(), ChatID: "c1", Role: role, Content: strings.Repeat("word ", 100), CreatedAt: start.Add(time.Duration(i) * time.Minute)}
+		if err := store.CreateMessage(msg); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+	}
+	srv.Enqueue(
+		aitest.Response{Content: "They chatted at length."},
+		aitest.Response{Chunks: []string{"Hi again"}},
+	)
+
+	sendMessage(t, h, `{"userMessageId":"u1","content":"hi","options":{"num_ctx":1000}}`)
+
+	requests := srv.Requests()
+	if len(requests) != 2 || requests[0].Stream {
+		t.Fatalf("requests = %+v, want a summary before the answer", requests)
+	}
+	sent := requests[1].Messages
+	if !strings.Contains(sent[0].Content, "They chatted at length.") || len(sent) >= 13 {
+		t.Errorf("sent %d messages starting with %q, want the summary instead of the oldest", len(sent), sent[0].Content)
+	}
+
+	summary, err := store.GetChatSummary("c1")
+	if err != nil {
+		t.Fatalf("GetChatSummary: %v", err)
+	}
+	if summary == nil || summary.Summary != "They chatted at length." || summary.MessageCount != 13-(len(sent)-1) {
+		t.Errorf("stored summary = %+v, want it to stand for the messages left out", summary)
+	}
+}
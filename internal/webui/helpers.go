@@ -2,11 +2,13 @@ package webui
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/misrab/clai/internal/ai"
 )
 
 // getURLParam extracts a URL parameter from chi router
@@ -35,6 +37,36 @@ func respondError(w http.ResponseWriter, status int, message string) {
 	})
 }
 
+// aiErrorStatus maps an error from the AI provider to an HTTP status code
+func aiErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ai.ErrModelNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ai.ErrContextOverflow):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ai.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ai.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ai.ErrServer):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// metricsJSON renders generation metrics for the web UI, with durations in milliseconds
+func metricsJSON(m ai.Metrics) map[string]interface{} {
+	return map[string]interface{}{
+		"model":                  m.Model,
+		"prompt_tokens":          m.PromptTokens,
+		"output_tokens":          m.OutputTokens,
+		"tokens_per_second":      m.TokensPerSecond(),
+		"time_to_first_token_ms": m.TimeToFirstToken.Milliseconds(),
+		"total_duration_ms":      m.TotalDuration.Milliseconds(),
+	}
+}
+
 // decodeJSON decodes a JSON request body into the target
 func decodeJSON(r *http.Request, target interface{}) error {
 	if err := json.NewDecoder(r.Body).Decode(target); err != nil {
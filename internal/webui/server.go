@@ -1,6 +1,7 @@
 package webui
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"io/fs"
@@ -12,11 +13,15 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/misrab/clai/internal/ai"
 	"github.com/misrab/clai/internal/storage"
 )
 
-// Start starts the web UI server with the provided embedded filesystem
-func Start(distFiles embed.FS, port int, openBrowser bool) error {
+// Start starts the web UI server with the provided embedded filesystem.
+// newProvider is used to build the AI provider for each chat request;
+// defaultModel is preloaded when the server starts, and contextOpts keep long
+// chats within the model's context window.
+func Start(distFiles embed.FS, port int, openBrowser bool, defaultModel string, newProvider ProviderFactory, contextOpts ContextOptions) error {
 	// Initialize storage
 	store, err := storage.NewStore()
 	if err != nil {
@@ -30,6 +35,9 @@ func Start(distFiles embed.FS, port int, openBrowser bool) error {
 		return fmt.Errorf("failed to access embedded files: %w", err)
 	}
 
+	// Load the default model in the background so the first message doesn't wait for it
+	go preloadModel(defaultModel, newProvider)
+
 	// Create chi router
 	r := chi.NewRouter()
 
@@ -52,9 +60,10 @@ func Start(distFiles embed.FS, port int, openBrowser bool) error {
 			r.Get("/", HandleGetChat(store))
 			r.Put("/", HandleUpdateChat(store))
 			r.Delete("/", HandleDeleteChat(store))
-			r.Post("/send", HandleSendMessage(store))
+			r.Post("/send", HandleSendMessage(store, newProvider, contextOpts))
 		})
 	})
+	r.Get("/api/attachments/{id}", HandleGetAttachment(store))
 
 	// Serve embedded files
 	r.Handle("/*", http.FileServer(http.FS(distFS)))
@@ -76,6 +85,25 @@ func Start(distFiles embed.FS, port int, openBrowser bool) error {
 	return http.ListenAndServe(":"+fmt.Sprintf("%d", port), r)
 }
 
+// preloadModel loads a model into memory when the provider supports it.
+// Failures are only logged; the first chat request reports the real problem.
+func preloadModel(model string, newProvider ProviderFactory) {
+	provider, err := newProvider(model, ai.Options{})
+	if err != nil {
+		return
+	}
+	if fallback, ok := provider.(*ai.FallbackProvider); ok {
+		provider = fallback.Primary()
+	}
+	manager, ok := provider.(ai.ModelManager)
+	if !ok {
+		return
+	}
+	if err := manager.LoadModel(context.Background(), model); err != nil {
+		fmt.Printf("Failed to preload model %s: %v\n", model, err)
+	}
+}
+
 // openURL opens a URL in the default browser
 func openURL(url string) {
 	var cmd *exec.Cmd
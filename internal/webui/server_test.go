@@ -0,0 +1,31 @@
+package webui
+
+import (
+	"testing"
+
+	"github.com/misrab/clai/internal/ai"
+	"github.com/misrab/clai/internal/ai/aitest"
+)
+
+func TestPreloadModelKeepsAlive(t *testing.T) {
+	srv := aitest.NewServer(t)
+	// Like the CLI's, the factory wraps the provider for fallback models
+	newProvider := func(model string, opts ai.Options) (ai.Provider, error) {
+		return ai.NewFallbackProvider([]string{model, "other:latest"}, func(model string) (ai.Provider, error) {
+			cfg := srv.Config()
+			cfg.Model = model
+			cfg.KeepAlive = "-1"
+			return ai.NewProvider(cfg)
+		})
+	}
+
+	preloadModel(aitest.DefaultModel, newProvider)
+
+	requests := srv.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if req := requests[0]; req.Path != "/api/generate" || req.Model != aitest.DefaultModel || req.Prompt != "" || req.KeepAlive != "-1" {
+		t.Errorf("request = %+v, want an empty prompt for the model with keep_alive -1", req)
+	}
+}
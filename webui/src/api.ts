@@ -1,4 +1,4 @@
-import { ChatTab, Message } from './types'
+import { ChatTab, GenerationOptions, ImageUpload, Message } from './types'
 
 const API_BASE = '/api'
 
@@ -62,13 +62,21 @@ export const api = {
     if (!response.ok) throw new Error('Failed to delete chat')
   },
 
+  // URL of a stored attachment, e.g. for an <img> src
+  attachmentURL(id: string): string {
+    return `${API_BASE}/attachments/${id}`
+  },
+
   // Send a user message and get AI response via SSE streaming
   async sendMessage(
     chatId: string, 
     userMessageId: string, 
     content: string,
     onChunk: (chunk: string) => void,
-    model?: string
+    model?: string,
+    options?: GenerationOptions,
+    images?: ImageUpload[],
+    onThinking?: (chunk: string) => void
   ): Promise<Message> {
     const response = await fetch(`${API_BASE}/chats/${chatId}/send`, {
       method: 'POST',
@@ -76,7 +84,9 @@ export const api = {
       body: JSON.stringify({ 
         userMessageId, 
         content,
-        ...(model && { model })
+        ...(model && { model }),
+        ...(options && { options }),
+        ...(images?.length && { images })
       })
     })
 
@@ -112,12 +122,17 @@ export const api = {
           if (data.chunk) {
             onChunk(data.chunk)
           }
+
+          if (!data.done && data.thinking) {
+            onThinking?.(data.thinking)
+          }
           
           if (data.done && data.content) {
             assistantMessage = {
               id: data.id,
               role: 'assistant',
-              content: data.content
+              content: data.content,
+              ...(data.thinking && { thinking: data.thinking })
             }
           }
         }
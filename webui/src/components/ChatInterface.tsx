@@ -36,6 +36,12 @@ export function ChatInterface({ tab, onInputChange, onSend }: ChatInterfaceProps
           <>
             {tab.messages.map(message => (
               <div key={message.id} className={`message ${message.role}`}>
+                {message.thinking && (
+                  <details className="message-thinking">
+                    <summary>Thinking</summary>
+                    <div className="message-content">{message.thinking}</div>
+                  </details>
+                )}
                 <div className="message-content">{message.content}</div>
               </div>
             ))}
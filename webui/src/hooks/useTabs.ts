@@ -208,6 +208,24 @@ export function useTabs() {
               )
             }
           }))
+        },
+        undefined,
+        undefined,
+        undefined,
+        (chunk: string) => {
+          // Collect the model's reasoning separately so it can be folded
+          setTabs(prevTabs => prevTabs.map(t => {
+            if (t.id !== tabId) return t
+
+            return {
+              ...t,
+              messages: t.messages.map(m =>
+                m.id === assistantMessageId
+                  ? { ...m, thinking: (m.thinking ?? '') + chunk }
+                  : m
+              )
+            }
+          }))
         }
       )
     } catch (error) {
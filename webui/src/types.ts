@@ -1,7 +1,24 @@
 export interface Message {
   id: string
-  role: 'user' | 'assistant'
+  role: 'user' | 'assistant' | 'tool_call' | 'tool_result'
   content: string
+  tool_name?: string
+  // Reasoning of a thinking model, shown folded
+  thinking?: string
+  attachments?: Attachment[]
+}
+
+// File stored with a message; its data is served from /api/attachments/{id}
+export interface Attachment {
+  id: string
+  filename: string
+  mime_type: string
+}
+
+// Image sent with a message; data is base64-encoded
+export interface ImageUpload {
+  filename: string
+  data: string
 }
 
 export interface ChatTab {
@@ -11,3 +28,14 @@ export interface ChatTab {
   input: string
 }
 
+
+// Generation options accepted by POST /api/chats/{id}/send
+export interface GenerationOptions {
+  temperature?: number
+  top_p?: number
+  top_k?: number
+  seed?: number
+  num_ctx?: number
+  num_predict?: number
+  stop?: string[]
+}